@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"familydrive/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 初始化管理员：FAMILYDRIVE_ADMIN_IDS（逗号分隔的用户 ID）中的账号启动时设为管理员；
+// 未配置且还没有管理员时，最早注册的账号成为管理员
+func initAdmin() error {
+	var ids []int64
+	for _, s := range strings.Split(os.Getenv("FAMILYDRIVE_ADMIN_IDS"), ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	promoted, err := authService.EnsureAdmins(ids)
+	if err != nil {
+		return err
+	}
+	for _, u := range promoted {
+		fmt.Printf("👑 已将 %s (ID: %d) 设为管理员\n", u.Username, u.ID)
+	}
+	return nil
+}
+
+// 当前用户是否为整台服务器的管理员：默认家庭（部署服务器的家庭）的管理员
+func isInstanceAdmin(c *gin.Context) bool {
+	return can(c, auth.PermAdmin) && householdID(c) == defaultHouseholdID
+}
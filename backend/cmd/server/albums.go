@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 相册信息结构体
+type AlbumInfo struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	SpaceID     int64  `json:"spaceId"`
+	OwnerID     int64  `json:"ownerId"`
+	CoverFileID int64  `json:"coverFileId"` // 0 表示相册为空
+	Count       int    `json:"count"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+func toAlbumInfo(a *models.Album) AlbumInfo {
+	return AlbumInfo{
+		ID:          a.ID,
+		Name:        a.Name,
+		Description: a.Description,
+		SpaceID:     a.SpaceID,
+		OwnerID:     a.OwnerID,
+		CoverFileID: a.Cover,
+		Count:       a.Count,
+		CreatedAt:   a.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   a.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+// 加载路径参数 :id 指定的相册，当前用户必须能访问其所在空间，失败时已写好响应
+func loadAlbum(c *gin.Context) *models.Album {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的相册ID"})
+		return nil
+	}
+	album, err := albumRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相册失败"})
+		return nil
+	}
+	if album == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
+		return nil
+	}
+	if !requireSpaceAccess(c, album.SpaceID) {
+		return nil
+	}
+	return album
+}
+
+// 加载当前用户拥有的相册，失败时已写好响应
+func loadOwnedAlbum(c *gin.Context) *models.Album {
+	album := loadAlbum(c)
+	if album == nil || !requireOwner(c, album.OwnerID) {
+		return nil
+	}
+	return album
+}
+
+// 列出相册：默认列出私人空间和家庭空间的全部相册，space=private|family 只看其一
+func listAlbums(c *gin.Context) {
+	spaceIDs, ok := visibleSpaceIDs(c)
+	if !ok {
+		return
+	}
+	albums, err := albumRepo.ListBySpaces(spaceIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相册失败"})
+		return
+	}
+
+	list := make([]AlbumInfo, 0, len(albums))
+	for i := range albums {
+		list = append(list, toAlbumInfo(&albums[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// 创建相册：{"name": "...", "description": "...", "space": "private|family"}
+func createAlbum(c *gin.Context) {
+	var request struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+		Space       string `json:"space"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "相册名称不能为空"})
+		return
+	}
+	space, ok := resolveSpace(c, request.Space)
+	if !ok {
+		return
+	}
+
+	album := &models.Album{
+		OwnerID:     int64(c.GetInt("userID")),
+		SpaceID:     space.ID,
+		Name:        strings.TrimSpace(request.Name),
+		Description: request.Description,
+	}
+	if err := albumRepo.Create(album); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建相册失败"})
+		return
+	}
+
+	fmt.Printf("🖼️  创建相册: %s\n", album.Name)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    toAlbumInfo(album),
+		"message": "相册创建成功",
+	})
+}
+
+// 相册详情，按顺序列出其中的文件。家庭相册里的文件被移进别人的私人空间后不再显示
+func getAlbum(c *gin.Context) {
+	album := loadAlbum(c)
+	if album == nil {
+		return
+	}
+	private, err := privateSpace(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+		return
+	}
+	family, err := familySpace(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+		return
+	}
+	records, err := albumFiles(album, family.ID, private.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相册失败"})
+		return
+	}
+	files, err := fileInfos(c, records)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相册失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"album": toAlbumInfo(album),
+			"files": files,
+		},
+	})
+}
+
+// 按顺序列出相册中某个用户能看到的文件：所在家庭空间 familyID 的文件和该用户私人空间 privateID 的文件
+func albumFiles(album *models.Album, familyID, privateID int64) ([]models.File, error) {
+	records, err := albumRepo.Files(album.ID)
+	if err != nil {
+		return nil, err
+	}
+	visible := records[:0]
+	for i := range records {
+		if records[i].SpaceID == familyID || records[i].SpaceID == privateID {
+			visible = append(visible, records[i])
+		}
+	}
+	return visible, nil
+}
+
+// 修改相册名称、描述和封面（封面必须是相册中的文件，0 表示使用第一张）
+func updateAlbum(c *gin.Context) {
+	album := loadOwnedAlbum(c)
+	if album == nil {
+		return
+	}
+	var request struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+		CoverFileID *int64  `json:"cover_file_id"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "相册名称不能为空"})
+			return
+		}
+		album.Name = name
+	}
+	if request.Description != nil {
+		album.Description = *request.Description
+	}
+	if request.CoverFileID != nil {
+		if id := *request.CoverFileID; id != 0 {
+			ok, err := albumRepo.HasFile(album.ID, id)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相册失败"})
+				return
+			}
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "封面必须是相册中的文件"})
+				return
+			}
+		}
+		album.CoverFileID = *request.CoverFileID
+	}
+
+	if err := albumRepo.Update(album); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新相册失败"})
+		return
+	}
+	respondAlbum(c, album.ID, "相册已更新")
+}
+
+// 删除相册及其分享，相册中的文件不受影响
+func deleteAlbum(c *gin.Context) {
+	album := loadOwnedAlbum(c)
+	if album == nil {
+		return
+	}
+	if err := albumRepo.Delete(album.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除相册失败"})
+		return
+	}
+	if err := shareRepo.DeleteByAlbum(album.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除相册分享失败"})
+		return
+	}
+
+	fmt.Printf("🗑️  删除相册: %s\n", album.Name)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "相册已删除"})
+}
+
+// 读取请求中的 file_ids，失败时已写好响应
+func bindAlbumFileIDs(c *gin.Context) ([]int64, bool) {
+	var request struct {
+		FileIDs []int64 `json:"file_ids"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.FileIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择文件"})
+		return nil, false
+	}
+	return request.FileIDs, true
+}
+
+// 把文件加入相册：{"file_ids": [...]}。私人相册可以收录私人和家庭空间的文件，
+// 家庭相册只能收录家庭空间的文件，避免私人文件被其他成员看到
+func addAlbumFiles(c *gin.Context) {
+	album := loadOwnedAlbum(c)
+	if album == nil {
+		return
+	}
+	ids, ok := bindAlbumFileIDs(c)
+	if !ok {
+		return
+	}
+	albumSpace, err := spaceRepo.GetByID(album.SpaceID)
+	if err != nil || albumSpace == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+		return
+	}
+
+	for _, id := range ids {
+		record, err := fileRepo.GetByID(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件记录失败"})
+			return
+		}
+		if record == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在", "fileId": id})
+			return
+		}
+		if !requireSpaceAccess(c, record.SpaceID) {
+			return
+		}
+		if albumSpace.Kind == models.SpaceFamily && record.SpaceID != album.SpaceID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "家庭相册只能收录家庭空间的文件", "fileId": id})
+			return
+		}
+	}
+
+	added, err := albumRepo.AddFiles(album.ID, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新相册失败"})
+		return
+	}
+	respondAlbum(c, album.ID, fmt.Sprintf("已加入 %d 个文件", added))
+}
+
+// 从相册中移除文件：{"file_ids": [...]}，文件本身不受影响
+func removeAlbumFiles(c *gin.Context) {
+	album := loadOwnedAlbum(c)
+	if album == nil {
+		return
+	}
+	ids, ok := bindAlbumFileIDs(c)
+	if !ok {
+		return
+	}
+	if err := albumRepo.RemoveFiles(album.ID, ids); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新相册失败"})
+		return
+	}
+	respondAlbum(c, album.ID, "已从相册移除")
+}
+
+// 调整相册顺序：{"file_ids": [...]} 为新的顺序，未列出的文件保持原有顺序排在后面
+func reorderAlbum(c *gin.Context) {
+	album := loadOwnedAlbum(c)
+	if album == nil {
+		return
+	}
+	ids, ok := bindAlbumFileIDs(c)
+	if !ok {
+		return
+	}
+	if err := albumRepo.Reorder(album.ID, ids); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新相册失败"})
+		return
+	}
+	respondAlbum(c, album.ID, "相册顺序已更新")
+}
+
+// 返回修改后的相册信息
+func respondAlbum(c *gin.Context, id int64, message string) {
+	album, err := albumRepo.GetByID(id)
+	if err != nil || album == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相册失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toAlbumInfo(album),
+		"message": message,
+	})
+}
+
+// 创建相册分享链接，访问时显示只读的相册页面
+func createAlbumShare(c *gin.Context) {
+	album := loadOwnedAlbum(c)
+	if album == nil {
+		return
+	}
+	saveShare(c, &models.Share{AlbumID: album.ID, Filename: album.Name})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ZIP 中的一项：File 为 nil 时表示目录
+type archiveEntry struct {
+	Name     string
+	File     *models.File
+	Modified time.Time
+}
+
+// 按目录结构展开 folder 整棵子树，folder 自身在 ZIP 中的路径为 root（以 / 结尾）
+func folderEntries(folder *models.Folder, root string) ([]archiveEntry, error) {
+	tree, err := folderRepo.Subtree(folder.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 子树按层级排列，父目录的路径总是先算好
+	paths := map[int64]string{folder.ID: root}
+	var entries []archiveEntry
+	for _, f := range tree {
+		if f.ID != folder.ID {
+			paths[f.ID] = paths[f.ParentID] + f.Name + "/"
+		}
+		entries = append(entries, archiveEntry{Name: paths[f.ID], Modified: f.UpdatedAt})
+
+		files, err := fileRepo.ListByFolder(f.SpaceID, f.ID, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		for i := range files {
+			entries = append(entries, archiveEntry{Name: paths[f.ID] + files[i].Name, File: &files[i], Modified: files[i].UpdatedAt})
+		}
+	}
+	return entries, nil
+}
+
+// 名称重复时加上序号：a.txt、a (2).txt ……
+func uniqueEntryName(used map[string]bool, name string) string {
+	dir := strings.HasSuffix(name, "/")
+	base := strings.TrimSuffix(name, "/")
+	ext := ""
+	if !dir {
+		ext = path.Ext(base)
+		base = strings.TrimSuffix(base, ext)
+	}
+	candidate := name
+	for n := 2; used[candidate]; n++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if dir {
+			candidate += "/"
+		}
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// 已压缩过的格式直接存储，省下树莓派的 CPU
+func zipMethod(contentType string) uint16 {
+	for _, prefix := range []string{"image/", "video/", "audio/", "application/zip", "application/x-7z", "application/gzip", "application/x-rar"} {
+		if strings.HasPrefix(contentType, prefix) {
+			return zip.Store
+		}
+	}
+	return zip.Deflate
+}
+
+// 边读取 blob 边写出 ZIP，不在磁盘上暂存。响应头发出后出错只能中断连接，
+// 此时不写中央目录，客户端会发现压缩包不完整
+func streamArchive(c *gin.Context, name string, entries []archiveEntry) {
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", contentDisposition("attachment", name))
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	zw := zip.NewWriter(c.Writer)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.Name, Modified: e.Modified, Method: zip.Store}
+		// 文件名按 UTF-8 编码，中文名在各平台解压都不会乱码
+		header.Flags |= 0x800
+		if e.File != nil {
+			header.Method = zipMethod(e.File.ContentType)
+			header.UncompressedSize64 = uint64(e.File.Size)
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			fmt.Printf("⚠️  打包下载中断: %v\n", err)
+			return
+		}
+		if e.File == nil || e.File.Size == 0 {
+			continue
+		}
+
+		r, err := store.Get(ctx, blobPath(e.File.Blob), 0, -1)
+		if err == nil {
+			_, err = io.Copy(w, r)
+			r.Close()
+		}
+		if err != nil {
+			fmt.Printf("⚠️  打包下载中断: %s: %v\n", e.Name, err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		fmt.Printf("⚠️  打包下载中断: %v\n", err)
+	}
+}
+
+// 把选中的文件和目录打包为 ZIP 下载：{"file_ids": [...], "folder_ids": [...], "name": "可选的压缩包名"}
+func downloadArchive(c *gin.Context) {
+	var request struct {
+		FileIDs   []int64 `json:"file_ids"`
+		FolderIDs []int64 `json:"folder_ids"`
+		Name      string  `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.FileIDs)+len(request.FolderIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择要下载的文件或目录"})
+		return
+	}
+
+	used := map[string]bool{}
+	var entries []archiveEntry
+	var single string
+	for _, id := range request.FolderIDs {
+		folder, err := folderRepo.GetByID(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+			return
+		}
+		if folder == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "目录不存在", "folderId": id})
+			return
+		}
+		if !requireSpaceAccess(c, folder.SpaceID) {
+			return
+		}
+		// 同名的顶层目录加序号
+		list, err := folderEntries(folder, uniqueEntryName(used, folder.Name+"/"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+			return
+		}
+		entries = append(entries, list...)
+		single = folder.Name
+	}
+	for _, id := range request.FileIDs {
+		record, err := fileRepo.GetByID(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件记录失败"})
+			return
+		}
+		if record == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在", "fileId": id})
+			return
+		}
+		if !requireSpaceAccess(c, record.SpaceID) {
+			return
+		}
+		entries = append(entries, archiveEntry{Name: uniqueEntryName(used, record.Name), File: record, Modified: record.UpdatedAt})
+		single = strings.TrimSuffix(record.Name, path.Ext(record.Name))
+	}
+
+	name := request.Name
+	switch {
+	case name != "":
+	case len(request.FileIDs)+len(request.FolderIDs) == 1:
+		name = single
+	default:
+		name = "打包下载-" + time.Now().Format("20060102-150405")
+	}
+	if !strings.HasSuffix(strings.ToLower(name), ".zip") {
+		name += ".zip"
+	}
+
+	fmt.Printf("🗜️  打包下载: %s (%d 项)\n", name, len(entries))
+	streamArchive(c, name, entries)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"familydrive/internal/auth"
+	appdb "familydrive/internal/db"
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	authService *auth.Service
+	authBackend = "sqlite" // 账号所在的数据库
+	authDB      *sql.DB
+)
+
+// 初始化认证服务。账号默认与文件元数据同在 SQLite，
+// FAMILYDRIVE_AUTH_DRIVER=mysql 时放在 FAMILYDRIVE_AUTH_DSN 指定的 MySQL 中；
+// 令牌有效期可通过 FAMILYDRIVE_ACCESS_TOKEN_TTL、FAMILYDRIVE_REFRESH_TOKEN_TTL 配置，
+// FAMILYDRIVE_OPEN_REGISTRATION=false 时关闭开放注册，只能凭邀请码注册
+func initAuth() error {
+	conn := appdb.DB()
+	switch driver := os.Getenv("FAMILYDRIVE_AUTH_DRIVER"); driver {
+	case "", "sqlite":
+	case "mysql":
+		dsn := os.Getenv("FAMILYDRIVE_AUTH_DSN")
+		if dsn == "" {
+			return errors.New("使用 MySQL 时需要设置 FAMILYDRIVE_AUTH_DSN")
+		}
+		var err error
+		if conn, err = appdb.OpenMySQL(dsn); err != nil {
+			return err
+		}
+		authBackend = driver
+	default:
+		return fmt.Errorf("不支持的认证数据库: %s", driver)
+	}
+
+	authDB = conn
+	authService = auth.NewService(conn)
+	if v := os.Getenv("FAMILYDRIVE_ACCESS_TOKEN_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			authService.AccessTTL = d
+		}
+	}
+	if v := os.Getenv("FAMILYDRIVE_REFRESH_TOKEN_TTL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			authService.RefreshTTL = d
+		}
+	}
+	if v := os.Getenv("FAMILYDRIVE_OPEN_REGISTRATION"); v != "" {
+		if open, err := strconv.ParseBool(v); err == nil {
+			authService.OpenRegistration = open
+		}
+	}
+	return nil
+}
+
+// 接口返回的用户信息
+func userInfo(u *models.User) gin.H {
+	return gin.H{
+		"id":           u.ID,
+		"username":     u.Username,
+		"email":        u.Email,
+		"role":         u.Role,
+		"household_id": u.HouseholdID,
+	}
+}
+
+// 登录和刷新的响应：用户信息加一对令牌
+func tokenResponse(u *models.User, tokens *auth.Tokens) gin.H {
+	return gin.H{
+		"user":          userInfo(u),
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    "Bearer",
+		"expires_in":    tokens.ExpiresIn,
+	}
+}
+
+// 登录：{"email": "...", "password": "...", "device_name": "妈妈的手机"}，
+// device_name 可选，为空时按 User-Agent 推断
+func handleLogin(c *gin.Context) {
+	var request struct {
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		DeviceName string `json:"device_name"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+
+	fmt.Printf("🔐 登录尝试: %s\n", request.Email)
+	user, tokens, err := authService.Login(request.Email, request.Password, auth.Device{
+		Name:      request.DeviceName,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+		return
+	}
+
+	fmt.Printf("✅ 登录成功: %s (ID: %d)\n", user.Username, user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "登录成功",
+		"data":    tokenResponse(user, tokens),
+	})
+}
+
+// 注册：{"username": "...", "email": "...", "password": "...", "invite_code": "..."}，
+// 带邀请码时加入邀请的家庭，关闭开放注册后邀请码必填
+func handleRegister(c *gin.Context) {
+	var request struct {
+		Username   string `json:"username"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		InviteCode string `json:"invite_code"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+
+	fmt.Printf("👤 注册用户: %s (%s)\n", request.Username, request.Email)
+	user, err := authService.Register(request.Username, request.Email, request.Password, request.InviteCode)
+	switch {
+	case errors.Is(err, auth.ErrRegistrationClosed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, auth.ErrEmailTaken), errors.Is(err, auth.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, auth.ErrMissingFields), errors.Is(err, auth.ErrInvalidUsername),
+		errors.Is(err, auth.ErrInvalidEmail), errors.Is(err, auth.ErrWeakPassword), errors.Is(err, auth.ErrInvalidInvitation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注册失败，请重试"})
+		return
+	}
+
+	fmt.Printf("✅ 注册成功: %s (%s) ID: %d\n", user.Username, user.Email, user.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "注册成功",
+		"data": gin.H{
+			"user": userInfo(user),
+		},
+	})
+}
+
+// 用刷新令牌换取一对新令牌：{"refresh_token": "..."}。旧的刷新令牌随即作废，客户端需保存新的
+func handleRefresh(c *gin.Context) {
+	var request struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 refresh_token"})
+		return
+	}
+
+	user, tokens, err := authService.Refresh(request.RefreshToken, c.ClientIP())
+	if errors.Is(err, auth.ErrRefreshTokenReused) {
+		fmt.Printf("⚠️  刷新令牌被重复使用，已作废该会话 (%s)\n", c.ClientIP())
+	}
+	if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, auth.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "令牌已刷新",
+		"data":    tokenResponse(user, tokens),
+	})
+}
+
+// 获取当前用户
+func handleGetCurrentUser(c *gin.Context) {
+	user, err := authService.User(int64(c.GetInt("userID")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+		return
+	}
+	if user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    userInfo(user),
+	})
+}
+
+// 登出：结束当前会话，访问令牌和刷新令牌随即失效
+func handleLogout(c *gin.Context) {
+	if err := authService.Logout(c.GetString("sessionID")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登出失败"})
+		return
+	}
+
+	fmt.Println("🚪 用户登出")
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "登出成功",
+	})
+}
+
+// 清理过期的刷新令牌和会话
+func purgeExpiredSessions() {
+	tokens, sessions, err := authService.PurgeExpired()
+	if err != nil {
+		fmt.Printf("⚠️  清理过期会话失败: %v\n", err)
+		return
+	}
+	if tokens > 0 || sessions > 0 {
+		fmt.Printf("🧹 已清理 %d 个过期会话、%d 个过期刷新令牌\n", sessions, tokens)
+	}
+}
+
+// 后台定期清理
+func startSessionJanitor(interval time.Duration) {
+	go func() {
+		for {
+			purgeExpiredSessions()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// 校验 Authorization: Bearer <token> 及其会话，把用户、角色、家庭和会话信息存入 Gin 上下文
+func GinAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供认证token"})
+			c.Abort()
+			return
+		}
+		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+
+		claims, err := authService.Authenticate(tokenString, c.ClientIP())
+		if errors.Is(err, auth.ErrSessionRevoked) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "token无效或已过期"})
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("email", claims.Email)
+		c.Set("role", claims.Role)
+		c.Set("householdID", claims.HouseholdID)
+		c.Set("sessionID", claims.SessionID)
+
+		fmt.Printf("🔐 用户认证: %s (ID: %d)\n", claims.Username, claims.UserID)
+		c.Next()
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"familydrive/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 内容寻址存储：文件内容按 sha256 存放在存储后端的 blobs/ab/cd/<sha256>，
+// 文件记录和历史版本只引用 blob，相同内容只保存一份。
+// blobMu 保证引用计数与存储中的增删一致
+var blobMu sync.Mutex
+
+// blob 在存储中的 key
+func blobPath(hash string) string {
+	return path.Join("blobs", hash[:2], hash[2:4], hash)
+}
+
+// 把本地 src 处已写好的内容（sha256 为 hash）放入存储并增加一次引用，
+// 内容已存在时直接丢弃 src
+func storeBlob(src, hash string, size int64) error {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	ctx := context.Background()
+	key := blobPath(hash)
+	if _, err := store.Stat(ctx, key); err == nil {
+		if err := os.Remove(src); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	} else if err := storage.PutFile(ctx, store, key, src); err != nil {
+		return err
+	}
+	return blobRepo.Acquire(hash, size)
+}
+
+// 为已在存储中的内容增加一次引用
+func acquireBlob(hash string, size int64) error {
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	if _, err := store.Stat(context.Background(), blobPath(hash)); err != nil {
+		return err
+	}
+	return blobRepo.Acquire(hash, size)
+}
+
+// 释放一次引用，没有引用时删除内容、缩略图和全文索引
+func releaseBlob(hash string) error {
+	if hash == "" {
+		return nil
+	}
+	blobMu.Lock()
+	defer blobMu.Unlock()
+
+	remaining, err := blobRepo.Release(hash)
+	if err != nil || remaining > 0 {
+		return err
+	}
+	if err := store.Delete(context.Background(), blobPath(hash)); err != nil {
+		return err
+	}
+	if err := deletePreview(hash); err != nil {
+		return err
+	}
+	if err := contentRepo.Delete(hash); err != nil {
+		return err
+	}
+	return blobRepo.Delete(hash)
+}
+
+// 内容是否在存储中
+func blobExists(hash string) (bool, error) {
+	if hash == "" {
+		return false, nil
+	}
+	_, err := store.Stat(context.Background(), blobPath(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// 发送 blob 内容，modTime 作为 Last-Modified。由 http.ServeContent 处理 Range、If-Range、
+// If-None-Match 和 If-Modified-Since；attachment 为 true 时作为附件下载，否则在浏览器内打开
+func serveBlob(c *gin.Context, hash, name, contentType string, modTime time.Time, attachment bool) {
+	if hash == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	// 内容按 sha256 寻址，校验和就是强 ETag
+	serveObject(c, blobPath(hash), blobETag(hash), name, contentType, modTime, attachment)
+}
+
+// 发送存储中 key 处的内容，etag 由调用方按内容给出
+func serveObject(c *gin.Context, key, etag, name, contentType string, modTime time.Time, attachment bool) {
+	ctx := c.Request.Context()
+	info, err := store.Stat(ctx, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取文件失败"})
+		return
+	}
+
+	disposition := "inline"
+	if attachment {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", contentDisposition(disposition, name))
+	// 每次都向服务器确认是否有变化
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	// 存储中的对象没有扩展名，按记录中的类型设置 Content-Type
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	r := storage.NewReader(ctx, store, key, info.Size)
+	defer r.Close()
+	http.ServeContent(c.Writer, c.Request, name, modTime, r)
+}
+
+// 计算 full 处文件的校验和并放入存储
+func ingestBlob(full string) (hash string, size int64, err error) {
+	info, err := os.Stat(full)
+	if err != nil {
+		return "", 0, err
+	}
+	if hash, err = fileChecksum(full); err != nil {
+		return "", 0, err
+	}
+	return hash, info.Size(), storeBlob(full, hash, info.Size())
+}
+
+// 自下而上删除搬空的目录，遇到非空目录为止
+func removeEmptyDirs(full string) {
+	root := filepath.Clean(uploadDir)
+	for dir := filepath.Dir(full); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
+
+// 引入内容寻址存储之前，文件和历史版本按路径直接存放，启动时逐个收进存储。
+// 磁盘上已找不到的文件留给 reconcileUploads 清理，找不到的历史版本直接删除
+func migrateToBlobs() error {
+	records, err := fileRepo.List()
+	if err != nil {
+		return err
+	}
+	files := 0
+	for i := range records {
+		rec := &records[i]
+		if rec.Blob != "" {
+			continue
+		}
+		full := filepath.Join(uploadDir, filepath.FromSlash(rec.Path))
+		hash, size, err := ingestBlob(full)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		removeEmptyDirs(full)
+		rec.Blob, rec.Checksum, rec.Size = hash, hash, size
+		if err := fileRepo.SetBlob(rec); err != nil {
+			return err
+		}
+		files++
+	}
+
+	versions, err := versionRepo.ListArchived()
+	if err != nil {
+		return err
+	}
+	archived := 0
+	for i := range versions {
+		v := &versions[i]
+		if strings.HasPrefix(v.Path, "blobs/") {
+			continue
+		}
+		full := filepath.Join(uploadDir, filepath.FromSlash(v.Path))
+		hash, size, err := ingestBlob(full)
+		if os.IsNotExist(err) {
+			if err := versionRepo.Delete(v.ID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		removeEmptyDirs(full)
+		v.Path, v.Checksum, v.Size = blobPath(hash), hash, size
+		if err := versionRepo.Archive(v); err != nil {
+			return err
+		}
+		archived++
+	}
+
+	if files+archived > 0 {
+		fmt.Printf("📦 已把 %d 个文件、%d 个历史版本收进内容存储\n", files, archived)
+	}
+	return nil
+}
+
+// 当前家庭的存储占用统计（管理员）
+func storageStats(c *gin.Context) {
+	ids, err := householdOwnerIDs(householdID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询存储统计失败"})
+		return
+	}
+	stats, err := blobRepo.HouseholdStats(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询存储统计失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"driver":        storageDriver,
+			"blobs":         stats.Blobs,
+			"references":    stats.References,
+			"logicalBytes":  stats.LogicalSize,
+			"physicalBytes": stats.PhysicalSize,
+			"savedBytes":    stats.LogicalSize - stats.PhysicalSize,
+		},
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 受密码保护的分享验证通过后发放的下载地址的有效期
+const signedURLTTL = 15 * time.Minute
+
+// 下载地址的签名密钥，可通过 FAMILYDRIVE_URL_SECRET 配置；
+// 未配置时每次启动随机生成，重启后已发放的地址失效
+var urlSecret []byte
+
+func initDownloads() {
+	if v := os.Getenv("FAMILYDRIVE_URL_SECRET"); v != "" {
+		urlSecret = []byte(v)
+		return
+	}
+	urlSecret = make([]byte, 32)
+	if _, err := rand.Read(urlSecret); err != nil {
+		panic("生成下载签名密钥失败: " + err.Error())
+	}
+}
+
+// blob 的强 ETag
+func blobETag(hash string) string {
+	return `"` + hash + `"`
+}
+
+// Content-Disposition 头：filename 是给旧客户端的 ASCII 名称，filename* 按 RFC 5987 以 UTF-8 编码原名
+func contentDisposition(disposition, name string) string {
+	fallback := strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' || r == '%' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	var encoded strings.Builder
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || strings.IndexByte("!#$&+-.^_`|~", b) >= 0 {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return fmt.Sprintf(`%s; filename="%s"; filename*=UTF-8''%s`, disposition, fallback, encoded.String())
+}
+
+// 分享下载地址的签名
+func shareSignature(token string, expires int64) string {
+	mac := hmac.New(sha256.New, urlSecret)
+	fmt.Fprintf(mac, "share\n%s\n%d", token, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 为分享生成 ttl 内有效的签名参数
+func signShare(token string, ttl time.Duration) url.Values {
+	expires := time.Now().Add(ttl).Unix()
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires, 10))
+	q.Set("sig", shareSignature(token, expires))
+	return q
+}
+
+// 生成分享的短期下载地址，过期前可反复请求（Range 续传、拖动进度）且不再计数
+func signedShareURL(token string, ttl time.Duration) (string, time.Time) {
+	q := signShare(token, ttl)
+	expires, _ := strconv.ParseInt(q.Get("expires"), 10, 64)
+	return shareURL(token) + "/file?" + q.Encode(), time.Unix(expires, 0)
+}
+
+// 校验请求中的分享签名参数，失败时已写好响应
+func verifyShareSignature(c *gin.Context, token string) bool {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(c.Query("sig")), []byte(shareSignature(token, expires))) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无效的下载地址"})
+		return false
+	}
+	if time.Now().Unix() > expires {
+		c.JSON(http.StatusGone, gin.H{"error": "下载地址已过期"})
+		return false
+	}
+	return true
+}
+
+// 通过签名地址下载分享的文件，目录分享下载整个目录的 ZIP，相册分享显示相册页面
+func downloadSignedShare(c *gin.Context) {
+	token := c.Param("token")
+	if !verifyShareSignature(c, token) {
+		return
+	}
+
+	// 发放地址时已计过访问次数，这里只要求分享仍然有效
+	share := loadLiveShare(c, token)
+	if share == nil {
+		return
+	}
+	target, ok := loadShareTarget(c, share)
+	if !ok {
+		return
+	}
+	switch {
+	case target.Album != nil:
+		// 沿用这次的签名，相册页面中的链接与它同时过期
+		q := url.Values{}
+		q.Set("expires", c.Query("expires"))
+		q.Set("sig", c.Query("sig"))
+		renderAlbumGallery(c, share, target.Album, q)
+	case target.Folder != nil:
+		streamFolderArchive(c, target.Folder)
+	default:
+		record := target.File
+		serveBlob(c, record.Blob, record.Name, record.ContentType, record.UpdatedAt, true)
+	}
+}
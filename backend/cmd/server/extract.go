@@ -0,0 +1,561 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"familydrive/internal/models"
+	"familydrive/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 解压限制，防止压缩炸弹：条目数、解压后的总大小，以及压缩比（zip 按条目，tar.gz 按整个解压流）。
+// 可通过 FAMILYDRIVE_EXTRACT_MAX_ENTRIES 和 FAMILYDRIVE_EXTRACT_MAX_SIZE 配置
+var (
+	extractMaxEntries = 10000
+	extractMaxSize    = int64(8 << 30)
+)
+
+const extractMaxRatio = 1000
+
+var (
+	errUnsupportedArchive = errors.New("不支持的压缩格式")
+	errArchiveTooLarge    = errors.New("解压后的内容超过上限")
+	errArchiveTooMany     = errors.New("压缩包中的条目过多")
+	errArchiveBomb        = errors.New("压缩比异常，疑似压缩炸弹")
+	errQuotaExceeded      = errors.New("存储空间不足")
+	errStopWalk           = errors.New("stop")
+)
+
+// 读取解压限制配置
+func initExtract() {
+	if v := os.Getenv("FAMILYDRIVE_EXTRACT_MAX_ENTRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			extractMaxEntries = n
+		}
+	}
+	if v := os.Getenv("FAMILYDRIVE_EXTRACT_MAX_SIZE"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			extractMaxSize = n
+		}
+	}
+}
+
+// 压缩包内的一个条目
+type archiveItem struct {
+	Name           string // 以 / 分隔、已清理的路径
+	Size           int64
+	CompressedSize int64 // 未知时为 -1
+	Modified       time.Time
+	IsDir          bool
+	Regular        bool // 可以读取内容的普通文件：不是链接，也没有加密
+}
+
+// 压缩包条目信息结构体
+type ArchiveEntryInfo struct {
+	Name           string `json:"name"`
+	Size           int64  `json:"size"`
+	CompressedSize int64  `json:"compressedSize"`
+	Modified       string `json:"modified"`
+	IsDir          bool   `json:"isDir"`
+}
+
+// 压缩包内的路径统一为 / 分隔；绝对路径和含 .. 的路径会越出目标目录（zip-slip），返回 false
+func cleanArchivePath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "/") || (len(name) > 1 && name[1] == ':') {
+		return "", false
+	}
+	var parts []string
+	for _, p := range strings.Split(name, "/") {
+		switch p {
+		case "", ".":
+			continue
+		case "..":
+			return "", false
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, "/"), len(parts) > 0
+}
+
+// 按文件头识别格式：zip（含自解压的 exe）、tar、tar.gz
+func detectArchiveFormat(r io.ReaderAt) (string, error) {
+	head := make([]byte, 512)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	head = head[:n]
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")), bytes.HasPrefix(head, []byte("MZ")):
+		return "zip", nil
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return "tar.gz", nil
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return "tar", nil
+	}
+	return "", errUnsupportedArchive
+}
+
+// 依次访问压缩包中的条目；open 读取普通文件的内容，fn 返回 errStopWalk 时提前结束
+func walkArchive(ctx context.Context, record *models.File, fn func(item *archiveItem, open func() (io.ReadCloser, error)) error) (string, error) {
+	if record.Blob == "" {
+		return "", fs.ErrNotExist
+	}
+	r := storage.NewReader(ctx, store, blobPath(record.Blob), record.Size)
+	defer r.Close()
+	return walkArchiveReader(r, record.Size, fn)
+}
+
+// 压缩包内容：zip 需要随机读取，tar 顺序读取
+type archiveSource interface {
+	io.ReaderAt
+	io.ReadSeeker
+}
+
+// 依次访问压缩包中的条目。条目超过 extractMaxEntries 时返回 errArchiveTooMany；
+// 压缩比异常的 zip 条目在 open 时返回 errArchiveBomb，tar.gz 则对整个解压流限制大小和压缩比
+func walkArchiveReader(r archiveSource, size int64, fn func(item *archiveItem, open func() (io.ReadCloser, error)) error) (string, error) {
+	format, err := detectArchiveFormat(r)
+	if err != nil {
+		return "", err
+	}
+	entries := 0
+
+	if format == "zip" {
+		// 自解压程序的 zip 数据附在 exe 之后，archive/zip 会自动定位
+		zr, err := zip.NewReader(r, size)
+		if err != nil {
+			return "", errUnsupportedArchive
+		}
+		for _, f := range zr.File {
+			if entries++; entries > extractMaxEntries {
+				return format, errArchiveTooMany
+			}
+			mode := f.Mode()
+			item := &archiveItem{
+				Size:           int64(f.UncompressedSize64),
+				CompressedSize: int64(f.CompressedSize64),
+				Modified:       f.Modified,
+				IsDir:          mode.IsDir(),
+				Regular:        mode.IsRegular() && f.Flags&0x1 == 0,
+			}
+			var ok bool
+			if item.Name, ok = cleanArchivePath(f.Name); !ok {
+				item.Name, item.Regular, item.IsDir = f.Name, false, false
+			}
+			open := f.Open
+			if item.CompressedSize > 0 && item.Size/item.CompressedSize > extractMaxRatio {
+				open = func() (io.ReadCloser, error) { return nil, errArchiveBomb }
+			}
+			if err := fn(item, open); err != nil {
+				return format, err
+			}
+		}
+		return format, nil
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	var src io.Reader = r
+	if format == "tar.gz" {
+		compressed := &countingReader{r: r}
+		gz, err := gzip.NewReader(compressed)
+		if err != nil {
+			return "", errUnsupportedArchive
+		}
+		defer gz.Close()
+		// 跳到下一个条目时也要解压前面条目的内容，所以整个解压流都要限制；
+		// 每个条目另有头部和对齐填充，不超过 1 KB
+		budget := extractMaxSize + int64(extractMaxEntries)*1024
+		src = &inflateLimitReader{
+			r:          &budgetReader{r: gz, budget: &budget, err: errArchiveTooLarge},
+			compressed: &compressed.n,
+		}
+	}
+	tr := tar.NewReader(src)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return format, nil
+		}
+		if errors.Is(err, errArchiveTooLarge) || errors.Is(err, errArchiveBomb) {
+			return format, err
+		}
+		if err != nil {
+			return format, errUnsupportedArchive
+		}
+		if entries++; entries > extractMaxEntries {
+			return format, errArchiveTooMany
+		}
+		item := &archiveItem{
+			Size:           h.Size,
+			CompressedSize: -1,
+			Modified:       h.ModTime,
+			IsDir:          h.Typeflag == tar.TypeDir,
+			Regular:        h.Typeflag == tar.TypeReg || h.Typeflag == tar.TypeRegA,
+		}
+		var ok bool
+		if item.Name, ok = cleanArchivePath(h.Name); !ok {
+			item.Name, item.Regular, item.IsDir = h.Name, false, false
+		}
+		if err := fn(item, func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }); err != nil {
+			return format, err
+		}
+	}
+}
+
+// 统计读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// 解压流的压缩比检查：解压超过 extractRatioMinSize 后，解压量与已读的压缩数据之比超过
+// extractMaxRatio 时返回 errArchiveBomb（之后的读取都返回该错误）
+type inflateLimitReader struct {
+	r          io.Reader
+	compressed *int64
+	n          int64
+	err        error
+}
+
+// 解压量小于它时不检查压缩比，避免少量空白数据误判
+const extractRatioMinSize = 1 << 20
+
+func (l *inflateLimitReader) Read(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, l.err
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > extractRatioMinSize && l.n/max(*l.compressed, 1) > extractMaxRatio {
+		l.err = errArchiveBomb
+		return n, l.err
+	}
+	return n, err
+}
+
+// 在 budget 字节之内读取，超出时返回 err（条目头中的大小可能是伪造的，按实际读到的计）
+type budgetReader struct {
+	r      io.Reader
+	budget *int64
+	err    error
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if *b.budget <= 0 {
+		// 恰好用完时再确认一下是否已到末尾
+		var one [1]byte
+		if n, _ := b.r.Read(one[:]); n > 0 {
+			return 0, b.err
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > *b.budget {
+		p = p[:*b.budget]
+	}
+	n, err := b.r.Read(p)
+	*b.budget -= int64(n)
+	return n, err
+}
+
+// 压缩包相关错误对应的响应
+func archiveErrorResponse(c *gin.Context, err error) {
+	status, message := archiveError(err)
+	c.JSON(status, gin.H{"error": message})
+}
+
+// 压缩包相关错误对应的状态码和提示
+func archiveError(err error) (int, string) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return http.StatusNotFound, "文件不存在"
+	case errors.Is(err, errUnsupportedArchive):
+		return http.StatusUnsupportedMediaType, "不支持的压缩格式，仅支持 zip、tar 和 tar.gz"
+	case errors.Is(err, errQuotaExceeded):
+		return http.StatusInsufficientStorage, "存储空间不足"
+	case errors.Is(err, errArchiveTooLarge), errors.Is(err, errArchiveTooMany), errors.Is(err, errArchiveBomb):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	case errors.Is(err, zip.ErrFormat), errors.Is(err, zip.ErrChecksum), errors.Is(err, gzip.ErrChecksum), errors.Is(err, io.ErrUnexpectedEOF):
+		return http.StatusUnprocessableEntity, "压缩包已损坏"
+	default:
+		return http.StatusInternalServerError, "读取压缩包失败"
+	}
+}
+
+// 列出压缩包中的条目（最多 FAMILYDRIVE_EXTRACT_MAX_ENTRIES 条）
+func listArchiveEntries(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil {
+		return
+	}
+
+	list := []ArchiveEntryInfo{}
+	var totalSize int64
+	truncated := false
+	format, err := walkArchive(c.Request.Context(), record, func(item *archiveItem, _ func() (io.ReadCloser, error)) error {
+		list = append(list, ArchiveEntryInfo{
+			Name:           item.Name,
+			Size:           item.Size,
+			CompressedSize: item.CompressedSize,
+			Modified:       item.Modified.Format(time.RFC3339),
+			IsDir:          item.IsDir,
+		})
+		totalSize += item.Size
+		return nil
+	})
+	if err == errArchiveTooMany {
+		truncated, err = true, nil
+	}
+	if err != nil {
+		archiveErrorResponse(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"format":    format,
+			"entries":   list,
+			"totalSize": totalSize,
+			"truncated": truncated,
+		},
+	})
+}
+
+// 下载压缩包中的单个条目：?name=条目路径
+func downloadArchiveEntry(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil {
+		return
+	}
+	name, ok := cleanArchivePath(c.Query("name"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的条目路径"})
+		return
+	}
+
+	found := false
+	_, err := walkArchive(c.Request.Context(), record, func(item *archiveItem, open func() (io.ReadCloser, error)) error {
+		if item.Name != name || !item.Regular {
+			return nil
+		}
+		if item.Size > extractMaxSize {
+			return errArchiveTooLarge
+		}
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		found = true
+		contentType := mime.TypeByExtension(path.Ext(name))
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		c.Header("Content-Disposition", contentDisposition("attachment", path.Base(name)))
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Length", strconv.FormatInt(item.Size, 10))
+		c.Status(http.StatusOK)
+		budget := item.Size
+		if _, err := io.Copy(c.Writer, &budgetReader{r: rc, budget: &budget, err: errArchiveTooLarge}); err != nil {
+			fmt.Printf("⚠️  下载压缩包条目中断: %s: %v\n", name, err)
+		}
+		return errStopWalk
+	})
+	if found {
+		return
+	}
+	if err != nil && err != errStopWalk {
+		archiveErrorResponse(c, err)
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "压缩包中没有该文件"})
+}
+
+// 把压缩包解压到网盘中：默认解压到压缩包所在目录下与其同名的子目录，
+// 也可用 folder_id，或 space + path 指定目标目录。同名文件视为覆盖（原内容成为历史版本），
+// 无法写入的条目（隐藏文件、链接、加密或路径越界的条目、与目录重名等）跳过并在结果中列出。
+// 中途超出限制或配额时停止，已解压的文件保留
+func extractArchive(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil {
+		return
+	}
+	userID := int64(c.GetInt("userID"))
+
+	var spaceID, baseID int64
+	if c.Query("folder_id") != "" || c.Query("space") != "" || c.Query("path") != "" {
+		var ok bool
+		if spaceID, baseID, ok = folderFromRequest(c, true); !ok {
+			return
+		}
+	} else {
+		dirName := strings.TrimSuffix(record.Name, path.Ext(record.Name))
+		dirName = strings.TrimSuffix(dirName, ".tar")
+		id, err := resolveFolderPathFrom(record.SpaceID, record.FolderID, dirName, true, userID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		spaceID, baseID = record.SpaceID, id
+	}
+
+	available, err := quotaAvailable(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询配额失败"})
+		return
+	}
+
+	folders := map[string]int64{"": baseID}
+	// 按需创建条目所在的目录
+	folderFor := func(dir string) (int64, error) {
+		if id, ok := folders[dir]; ok {
+			return id, nil
+		}
+		id, err := resolveFolderPathFrom(spaceID, baseID, dir, true, userID)
+		if err == nil {
+			folders[dir] = id
+		}
+		return id, err
+	}
+
+	budget := extractMaxSize
+	extracted := 0
+	skipped := []string{}
+	_, err = walkArchive(c.Request.Context(), record, func(item *archiveItem, open func() (io.ReadCloser, error)) error {
+		// macOS 打包时附带的资源文件
+		if item.Name == "__MACOSX" || strings.HasPrefix(item.Name, "__MACOSX/") {
+			return nil
+		}
+		if item.IsDir {
+			if _, err := folderFor(item.Name); err != nil {
+				skipped = append(skipped, item.Name)
+			}
+			return nil
+		}
+
+		dir, name := path.Split(item.Name)
+		if !item.Regular || !validEntryName(name) {
+			skipped = append(skipped, item.Name)
+			return nil
+		}
+		folderID, err := folderFor(strings.TrimSuffix(dir, "/"))
+		if err != nil {
+			skipped = append(skipped, item.Name)
+			return nil
+		}
+		if item.Size > budget {
+			return errArchiveTooLarge
+		}
+		if available >= 0 && item.Size > available {
+			return errQuotaExceeded
+		}
+
+		// 目录下已有同名子目录，或同名文件属于其他成员时跳过
+		if existing, err := folderRepo.GetChild(spaceID, folderID, name); err != nil {
+			return err
+		} else if existing != nil {
+			skipped = append(skipped, item.Name)
+			return nil
+		}
+		if existing, err := fileRepo.GetInFolder(spaceID, folderID, name); err != nil {
+			return err
+		} else if existing != nil && !canManage(c, existing.OwnerID) {
+			skipped = append(skipped, item.Name)
+			return nil
+		}
+
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		dst, err := newTempFile()
+		if err != nil {
+			return err
+		}
+		defer os.Remove(dst.Name())
+		defer dst.Close()
+
+		// 实际读到的内容同时受总大小和剩余配额限制
+		limit := budget
+		limitErr := errArchiveTooLarge
+		if available >= 0 && available < limit {
+			limit, limitErr = available, errQuotaExceeded
+		}
+		used := limit
+		hasher := sha256.New()
+		size, err := io.Copy(io.MultiWriter(dst, hasher), &budgetReader{r: rc, budget: &used, err: limitErr})
+		if err == nil {
+			err = dst.Close()
+		}
+		if err != nil {
+			return err
+		}
+		budget -= size
+		if available >= 0 {
+			available -= size
+		}
+
+		_, err = registerUpload(&models.File{
+			OwnerID:  userID,
+			SpaceID:  spaceID,
+			FolderID: folderID,
+			Name:     name,
+			Size:     size,
+			Checksum: hex.EncodeToString(hasher.Sum(nil)),
+			IsHidden: record.IsHidden,
+		}, dst.Name())
+		if err != nil {
+			return err
+		}
+		extracted++
+		return nil
+	})
+	// 中途失败时已解压的文件保留，告诉客户端解压到了哪里
+	if err != nil {
+		status, message := archiveError(err)
+		c.JSON(status, gin.H{"error": message, "extracted": extracted, "skipped": skipped})
+		return
+	}
+
+	fmt.Printf("📂 解压 %s: %d 个文件，跳过 %d 个\n", record.Name, extracted, len(skipped))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"spaceId":   spaceID,
+			"folderId":  baseID,
+			"extracted": extracted,
+			"skipped":   skipped,
+		},
+		"message": fmt.Sprintf("已解压 %d 个文件", extracted),
+	})
+}
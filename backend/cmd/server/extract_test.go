@@ -0,0 +1,211 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestCleanArchivePath(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"a.txt", "a.txt", true},
+		{"dir/a.txt", "dir/a.txt", true},
+		{"./dir//a.txt", "dir/a.txt", true},
+		{"dir/", "dir", true},
+		{`dir\sub\a.txt`, "dir/sub/a.txt", true},
+		{"dir/./a.txt", "dir/a.txt", true},
+		{"../a.txt", "", false},
+		{"dir/../../a.txt", "", false},
+		{"dir/../a.txt", "", false},
+		{`..\a.txt`, "", false},
+		{"/etc/passwd", "", false},
+		{`\windows\system32`, "", false},
+		{"C:/Windows/a.txt", "", false},
+		{`c:\a.txt`, "", false},
+		{"C:a.txt", "", false},
+		{"", "", false},
+		{"./", "", false},
+		{"..", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := cleanArchivePath(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("cleanArchivePath(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+type testEntry struct {
+	name string
+	body []byte
+}
+
+func buildZip(t *testing.T, entries []testEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(e.body)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTar(t *testing.T, entries []testEntry, compress bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.Writer = &buf
+	var gz *gzip.Writer
+	if compress {
+		gz, _ = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		w = gz
+	}
+	tw := tar.NewWriter(w)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(e.body)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if gz != nil {
+		gz.Close()
+	}
+	return buf.Bytes()
+}
+
+// 依次列出全部条目，并读取每个普通文件
+func walkAll(data []byte) ([]string, error) {
+	var names []string
+	_, err := walkArchiveReader(bytes.NewReader(data), int64(len(data)), func(item *archiveItem, open func() (io.ReadCloser, error)) error {
+		names = append(names, item.Name)
+		if !item.Regular {
+			return nil
+		}
+		rc, err := open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		_, err = io.Copy(io.Discard, rc)
+		return err
+	})
+	return names, err
+}
+
+// 只列出条目，不读取内容
+func listAll(data []byte) ([]string, error) {
+	var names []string
+	_, err := walkArchiveReader(bytes.NewReader(data), int64(len(data)), func(item *archiveItem, _ func() (io.ReadCloser, error)) error {
+		names = append(names, item.Name)
+		return nil
+	})
+	return names, err
+}
+
+func setExtractLimits(t *testing.T, entries int, size int64) {
+	t.Helper()
+	oldEntries, oldSize := extractMaxEntries, extractMaxSize
+	extractMaxEntries, extractMaxSize = entries, size
+	t.Cleanup(func() { extractMaxEntries, extractMaxSize = oldEntries, oldSize })
+}
+
+func TestWalkArchiveEntryLimit(t *testing.T) {
+	setExtractLimits(t, 3, 1<<20)
+	entries := make([]testEntry, 5)
+	for i := range entries {
+		entries[i] = testEntry{name: string(rune('a'+i)) + ".txt", body: []byte("hello")}
+	}
+	build := map[string]func([]testEntry) []byte{
+		"zip":    func(e []testEntry) []byte { return buildZip(t, e) },
+		"tar":    func(e []testEntry) []byte { return buildTar(t, e, false) },
+		"tar.gz": func(e []testEntry) []byte { return buildTar(t, e, true) },
+	}
+	for format, archive := range build {
+		names, err := listAll(archive(entries))
+		if !errors.Is(err, errArchiveTooMany) {
+			t.Errorf("%s: err = %v, want errArchiveTooMany", format, err)
+		}
+		if len(names) != 3 {
+			t.Errorf("%s: visited %d entries, want 3", format, len(names))
+		}
+		if _, err := walkAll(archive(entries[:3])); err != nil {
+			t.Errorf("%s: 3 entries: %v", format, err)
+		}
+	}
+}
+
+func TestWalkArchiveZipRatio(t *testing.T) {
+	setExtractLimits(t, 100, 8<<30)
+	// 条目头声明 1 GB 的内容只压缩成 100 字节
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateRaw(&zip.FileHeader{
+		Name:               "bomb.bin",
+		Method:             zip.Deflate,
+		CompressedSize64:   100,
+		UncompressedSize64: 1 << 30,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(make([]byte, 100))
+	zw.Close()
+
+	names, err := listAll(buf.Bytes())
+	if err != nil || len(names) != 1 {
+		t.Fatalf("list: %v, %v", names, err)
+	}
+	if _, err := walkAll(buf.Bytes()); !errors.Is(err, errArchiveBomb) {
+		t.Errorf("open: err = %v, want errArchiveBomb", err)
+	}
+}
+
+func TestWalkArchiveTarGzLimits(t *testing.T) {
+	zeros := make([]byte, 64<<20)
+	bomb := buildTar(t, []testEntry{{"zeros.bin", zeros}, {"after.txt", []byte("x")}}, true)
+	normal := buildTar(t, []testEntry{{"a.txt", bytes.Repeat([]byte("0123456789abcdef"), 1<<16)}, {"b.txt", []byte("x")}}, true)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		maxSize int64
+		read    bool
+		want    error
+	}{
+		// 只列出条目时跳过的内容也要解压，同样受限制
+		{"list over size", normal, 512 << 10, false, errArchiveTooLarge},
+		{"read over size", normal, 512 << 10, true, errArchiveTooLarge},
+		{"list within size", normal, 8 << 20, false, nil},
+		{"read within size", normal, 8 << 20, true, nil},
+		{"list bomb", bomb, 8 << 30, false, errArchiveBomb},
+		{"read bomb", bomb, 8 << 30, true, errArchiveBomb},
+	}
+	for _, tt := range tests {
+		setExtractLimits(t, 100, tt.maxSize)
+		walk := listAll
+		if tt.read {
+			walk = walkAll
+		}
+		_, err := walk(tt.data)
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
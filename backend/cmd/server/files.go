@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 把数据库记录转换为接口返回的结构
+func toFileInfo(f *models.File) FileInfo {
+	return FileInfo{
+		ID:         int(f.ID),
+		Name:       f.Name,
+		Size:       f.Size,
+		Type:       f.ContentType,
+		UploadTime: f.CreatedAt.Format(time.RFC3339),
+		IsHidden:   f.IsHidden,
+		SpaceID:    f.SpaceID,
+		FolderID:   f.FolderID,
+		OwnerID:    f.OwnerID,
+		Checksum:   f.Checksum,
+		UpdatedAt:  f.UpdatedAt.Format(time.RFC3339),
+		Photo:      toPhotoInfo(&f.Photo),
+	}
+}
+
+// 转换文件列表：标出哪些文件已有缩略图，并填上当前用户的标签、收藏和文件上的键值
+func fileInfos(c *gin.Context, records []models.File) ([]FileInfo, error) {
+	var blobs []string
+	ids := make([]int64, 0, len(records))
+	for i := range records {
+		if isPreviewable(records[i].ContentType) && records[i].Blob != "" {
+			blobs = append(blobs, records[i].Blob)
+		}
+		ids = append(ids, records[i].ID)
+	}
+	ready, err := previewRepo.Ready(blobs)
+	if err != nil {
+		return nil, err
+	}
+	userID := int64(c.GetInt("userID"))
+	tags, err := tagRepo.ForFiles(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	favorites, err := favoriteRepo.Of(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := metadataRepo.ForFiles(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]FileInfo, 0, len(records))
+	for i := range records {
+		info := toFileInfo(&records[i])
+		info.Preview = ready[records[i].Blob]
+		info.Tags = tags[records[i].ID]
+		info.Favorite = favorites[records[i].ID]
+		info.Metadata = metadata[records[i].ID]
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+// 按请求中的空间、目录参数和文件名查找文件记录，失败时已写好响应
+func lookupFile(c *gin.Context, name string) *models.File {
+	spaceID, folderID, ok := folderFromRequest(c, false)
+	if !ok {
+		return nil
+	}
+	record, err := fileRepo.GetInFolder(spaceID, folderID, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件记录失败"})
+		return nil
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return nil
+	}
+	return record
+}
+
+// 检查上传目标：文件名合法、目录下没有同名子目录，
+// 且同名文件（将被覆盖）归当前用户管理，失败时已写好响应
+func checkUploadTarget(c *gin.Context, spaceID, folderID int64, filename string) bool {
+	if filename == "" || filename == "." || filename == ".." || strings.ContainsAny(filename, `/\`) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件名"})
+		return false
+	}
+	existing, err := folderRepo.GetChild(spaceID, folderID, filename)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+		return false
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "同名目录已存在"})
+		return false
+	}
+	record, err := fileRepo.GetInFolder(spaceID, folderID, filename)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件记录失败"})
+		return false
+	}
+	if record != nil && !canManage(c, record.OwnerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "同名文件属于其他成员"})
+		return false
+	}
+	return true
+}
+
+// 在上传目录下创建临时文件，上传内容先写到这里，完整后再放入内容存储
+func newTempFile() (*os.File, error) {
+	dir := filepath.Join(uploadDir, ".tmp")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(dir, "upload-*")
+}
+
+// 登记上传：把 src 处已写好的内容（sha256 为 upload.Checksum）放入内容存储并保存记录
+func registerUpload(upload *models.File, src string) (*models.File, error) {
+	if upload.ContentType == "" {
+		upload.ContentType = detectContentType(upload.Name, src)
+	}
+	if err := storeBlob(src, upload.Checksum, upload.Size); err != nil {
+		return nil, err
+	}
+	return registerContent(upload)
+}
+
+// 保存记录，upload.Checksum 对应的 blob 已为本次上传增加过引用。
+// 同目录同名文件视为覆盖，原内容归档为历史版本，OwnerID 记为本次上传者
+func registerContent(upload *models.File) (*models.File, error) {
+	record, err := fileRepo.GetInFolder(upload.SpaceID, upload.FolderID, upload.Name)
+	if err == nil && record != nil {
+		err = archiveCurrentVersion(record)
+	}
+	if err != nil {
+		releaseBlob(upload.Checksum)
+		return nil, err
+	}
+
+	if record == nil {
+		record = upload
+		record.Path = storagePath(upload.SpaceID, upload.FolderID, upload.Name)
+		record.Blob = upload.Checksum
+		err = fileRepo.Create(record)
+	} else {
+		record.OwnerID = upload.OwnerID
+		record.Size = upload.Size
+		record.ContentType = upload.ContentType
+		record.Checksum = upload.Checksum
+		record.Blob = upload.Checksum
+		record.IsHidden = upload.IsHidden
+		err = fileRepo.Update(record)
+	}
+	if err != nil {
+		releaseBlob(upload.Checksum)
+		return nil, err
+	}
+
+	err = versionRepo.Create(&models.FileVersion{
+		FileID:      record.ID,
+		Size:        record.Size,
+		Checksum:    record.Checksum,
+		ContentType: record.ContentType,
+		UploaderID:  upload.OwnerID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	recordPhotoMeta(record)
+	enqueuePreview(record)
+	enqueueTextIndex(record)
+	return record, pruneVersions(record.ID)
+}
+
+// 删除文件记录、历史版本、相关分享、相册条目、标签、收藏和键值，并释放对内容的引用
+func removeFile(record *models.File) error {
+	if err := releaseBlob(record.Blob); err != nil {
+		return err
+	}
+	if err := removeVersions(record.ID); err != nil {
+		return err
+	}
+	if err := fileRepo.Delete(record.ID); err != nil {
+		return err
+	}
+	if err := albumRepo.RemoveFileEverywhere(record.ID); err != nil {
+		return err
+	}
+	if err := tagRepo.RemoveFileEverywhere(record.ID); err != nil {
+		return err
+	}
+	if err := favoriteRepo.RemoveFileEverywhere(record.ID); err != nil {
+		return err
+	}
+	if err := metadataRepo.RemoveFileEverywhere(record.ID); err != nil {
+		return err
+	}
+	return shareRepo.DeleteByFile(record.ID, record.Name)
+}
+
+// 计算文件的 sha256
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// 根据文件名的扩展名或 path 处的文件头推断类型
+func detectContentType(name, path string) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, _ := f.Read(buf)
+	return http.DetectContentType(buf[:n])
+}
+
+// 启动时把数据库与上传目录对齐：
+// 上传目录顶层散落的文件被收养进家庭空间根目录（同名文件视为新版本），
+// 内容已经丢失的记录被清理
+func reconcileUploads(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// 收养的文件归属未知，放入默认家庭的家庭空间
+	family, err := spaceRepo.Ensure(models.SpaceFamily, defaultHouseholdID)
+	if err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	adopted, updated := 0, 0
+	for _, entry := range entries {
+		// 子目录（内容存储、语音消息等）和隐藏标记文件不属于网盘文件
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		name := entry.Name()
+		fullPath := filepath.Join(dir, name)
+		sum, err := fileChecksum(fullPath)
+		if err != nil {
+			fmt.Printf("⚠️  计算校验和失败 %s: %v\n", name, err)
+			continue
+		}
+
+		rec, err := fileRepo.GetInFolder(family.ID, 0, name)
+		if err != nil {
+			return err
+		}
+		if rec != nil && rec.Checksum == sum {
+			os.Remove(fullPath)
+			continue
+		}
+		_, err = registerUpload(&models.File{
+			SpaceID:   family.ID,
+			Name:      name,
+			Size:      info.Size(),
+			Checksum:  sum,
+			IsHidden:  true,
+			CreatedAt: info.ModTime(),
+		}, fullPath)
+		if err != nil {
+			return err
+		}
+		if rec != nil {
+			updated++
+		} else {
+			adopted++
+		}
+	}
+
+	records, err := fileRepo.List()
+	if err != nil {
+		return err
+	}
+	objects, err := store.List(context.Background(), "blobs/")
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]bool, len(objects))
+	for _, o := range objects {
+		stored[o.Key] = true
+	}
+	removed := 0
+	for i := range records {
+		if records[i].Blob != "" && stored[blobPath(records[i].Blob)] {
+			continue
+		}
+		if err := removeFile(&records[i]); err != nil {
+			return err
+		}
+		removed++
+	}
+
+	fmt.Printf("📂 上传目录对齐完成：收养 %d 个，更新 %d 个，清理 %d 条失效记录\n", adopted, updated, removed)
+	return nil
+}
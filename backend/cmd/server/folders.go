@@ -0,0 +1,576 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 目录信息结构体
+type FolderInfo struct {
+	ID        int64  `json:"id"`
+	SpaceID   int64  `json:"spaceId"`
+	ParentID  int64  `json:"parentId"`
+	Name      string `json:"name"`
+	OwnerID   int64  `json:"ownerId"`
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+func toFolderInfo(f *models.Folder) FolderInfo {
+	return FolderInfo{
+		ID:        f.ID,
+		SpaceID:   f.SpaceID,
+		ParentID:  f.ParentID,
+		Name:      f.Name,
+		OwnerID:   f.OwnerID,
+		CreatedAt: f.CreatedAt.Format(time.RFC3339),
+		UpdatedAt: f.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// 读取分页参数 page（从 1 开始）和 page_size
+func pageParams(c *gin.Context) (page, size int) {
+	page, _ = strconv.Atoi(c.Query("page"))
+	if page < 1 {
+		page = 1
+	}
+	size, _ = strconv.Atoi(c.Query("page_size"))
+	if size <= 0 {
+		size = defaultPageSize
+	}
+	if size > maxPageSize {
+		size = maxPageSize
+	}
+	return page, size
+}
+
+// 文件/目录名校验：不能为空、不能包含路径分隔符
+func validEntryName(name string) bool {
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") {
+		return false
+	}
+	return !strings.ContainsAny(name, `/\`)
+}
+
+// 文件的逻辑存储路径，在未删除的文件中唯一：空间根目录下按空间 ID 分桶，子目录下按目录 ID 分桶。
+// 内容本身在内容存储中，见 blobs.go
+func storagePath(spaceID, folderID int64, name string) string {
+	if folderID == 0 {
+		return path.Join("spaces", strconv.FormatInt(spaceID, 10), name)
+	}
+	return path.Join("folders", strconv.FormatInt(folderID, 10), name)
+}
+
+// 在空间内按 "a/b/c" 形式的路径解析目录，create 为 true 时自动创建缺失的目录
+func resolveFolderPath(spaceID int64, p string, create bool, ownerID int64) (int64, error) {
+	return resolveFolderPathFrom(spaceID, 0, p, create, ownerID)
+}
+
+// 从 parentID 目录开始按相对路径解析目录
+func resolveFolderPathFrom(spaceID, parentID int64, p string, create bool, ownerID int64) (int64, error) {
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		if !validEntryName(name) {
+			return 0, fmt.Errorf("无效的目录名: %s", name)
+		}
+		folder, err := folderRepo.GetChild(spaceID, parentID, name)
+		if err != nil {
+			return 0, err
+		}
+		if folder == nil {
+			if !create {
+				return 0, errFolderNotFound
+			}
+			folder = &models.Folder{OwnerID: ownerID, SpaceID: spaceID, ParentID: parentID, Name: name}
+			if err := folderRepo.Create(folder); err != nil {
+				return 0, err
+			}
+		}
+		parentID = folder.ID
+	}
+	return parentID, nil
+}
+
+var errFolderNotFound = fmt.Errorf("目录不存在")
+
+// 从请求中读取目标空间和目录：优先 folder_id（或 parent_id），空间随目录确定；
+// 否则按 space 选择空间（默认私人空间），再按 path 定位目录。
+// 均可来自查询参数或表单字段，当前用户必须能访问该空间。失败时已写好响应
+func folderFromRequest(c *gin.Context, create bool) (spaceID, folderID int64, ok bool) {
+	idStr := c.Query("folder_id")
+	if idStr == "" {
+		idStr = c.Query("parent_id")
+	}
+	if idStr == "" {
+		idStr = c.Request.FormValue("folder_id")
+	}
+	p := c.Query("path")
+	if p == "" {
+		p = c.Request.FormValue("path")
+	}
+
+	var id int64
+	if idStr != "" {
+		var err error
+		id, err = strconv.ParseInt(idStr, 10, 64)
+		if err != nil || id < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的目录ID"})
+			return 0, 0, false
+		}
+	}
+	if id != 0 {
+		folder, err := folderRepo.GetByID(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+			return 0, 0, false
+		}
+		if folder == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "目录不存在"})
+			return 0, 0, false
+		}
+		if !requireSpaceAccess(c, folder.SpaceID) {
+			return 0, 0, false
+		}
+		return folder.SpaceID, id, true
+	}
+
+	space, ok := spaceFromRequest(c)
+	if !ok {
+		return 0, 0, false
+	}
+	if idStr != "" || p == "" {
+		return space.ID, 0, true
+	}
+
+	id, err := resolveFolderPath(space.ID, p, create, int64(c.GetInt("userID")))
+	if err == errFolderNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "目录不存在"})
+		return 0, 0, false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return 0, 0, false
+	}
+	return space.ID, id, true
+}
+
+// 加载路径参数 :id 指定的目录，当前用户必须能访问其所在空间，失败时已写好响应
+func loadFolder(c *gin.Context) *models.Folder {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的目录ID"})
+		return nil
+	}
+	folder, err := folderRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+		return nil
+	}
+	if folder == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "目录不存在"})
+		return nil
+	}
+	if !requireSpaceAccess(c, folder.SpaceID) {
+		return nil
+	}
+	return folder
+}
+
+// 检查目标目录下是否已有同名目录或文件
+func nameTaken(spaceID, parentID int64, name string) (bool, error) {
+	folder, err := folderRepo.GetChild(spaceID, parentID, name)
+	if err != nil || folder != nil {
+		return folder != nil, err
+	}
+	file, err := fileRepo.GetInFolder(spaceID, parentID, name)
+	return file != nil, err
+}
+
+// 创建目录
+func createFolder(c *gin.Context) {
+	var request struct {
+		Name     string `json:"name"`
+		ParentID int64  `json:"parent_id"`
+		Space    string `json:"space"` // 仅在根目录创建时使用
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if !validEntryName(request.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的目录名"})
+		return
+	}
+
+	var spaceID int64
+	if request.ParentID != 0 {
+		parent, err := folderRepo.GetByID(request.ParentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+			return
+		}
+		if parent == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "父目录不存在"})
+			return
+		}
+		if !requireSpaceAccess(c, parent.SpaceID) {
+			return
+		}
+		spaceID = parent.SpaceID
+	} else {
+		space, ok := resolveSpace(c, request.Space)
+		if !ok {
+			return
+		}
+		spaceID = space.ID
+	}
+
+	taken, err := nameTaken(spaceID, request.ParentID, request.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "同名文件或目录已存在"})
+		return
+	}
+
+	folder := &models.Folder{
+		OwnerID:  int64(c.GetInt("userID")),
+		SpaceID:  spaceID,
+		ParentID: request.ParentID,
+		Name:     request.Name,
+	}
+	if err := folderRepo.Create(folder); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建目录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toFolderInfo(folder),
+		"message": "目录创建成功",
+	})
+}
+
+// 分页列出目录内容：先子目录，后文件。
+// 带 tag 参数（可重复）时只列出带有全部这些标签的文件，不列子目录
+func listFolder(c *gin.Context) {
+	spaceID, folderID, ok := folderFromRequest(c, false)
+	if !ok {
+		return
+	}
+	tagIDs, found, ok := tagFilter(c)
+	if !ok {
+		return
+	}
+	page, size := pageParams(c)
+	offset := (page - 1) * size
+
+	totalFolders, totalFiles := 0, 0
+	var err error
+	if found && len(tagIDs) == 0 {
+		if totalFolders, err = folderRepo.CountChildren(spaceID, folderID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+			return
+		}
+	}
+	if found {
+		totalFiles, err = fileRepo.CountTaggedInFolder(spaceID, folderID, tagIDs)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件失败"})
+		return
+	}
+
+	folders := []FolderInfo{}
+	if offset < totalFolders {
+		children, err := folderRepo.ListChildren(spaceID, folderID, size, offset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+			return
+		}
+		for i := range children {
+			folders = append(folders, toFolderInfo(&children[i]))
+		}
+	}
+
+	files := []FileInfo{}
+	if remaining := size - len(folders); remaining > 0 && totalFiles > 0 {
+		fileOffset := offset - totalFolders
+		if fileOffset < 0 {
+			fileOffset = 0
+		}
+		records, err := fileRepo.ListTaggedInFolder(spaceID, folderID, tagIDs, remaining, fileOffset)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件失败"})
+			return
+		}
+		if files, err = fileInfos(c, records); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件失败"})
+			return
+		}
+	}
+
+	chain, err := folderRepo.Ancestors(folderID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+		return
+	}
+	breadcrumbs := []FolderInfo{}
+	for i := range chain {
+		breadcrumbs = append(breadcrumbs, toFolderInfo(&chain[i]))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"spaceId":      spaceID,
+			"folderId":     folderID,
+			"breadcrumbs":  breadcrumbs,
+			"folders":      folders,
+			"files":        files,
+			"totalFolders": totalFolders,
+			"totalFiles":   totalFiles,
+			"page":         page,
+			"pageSize":     size,
+		},
+	})
+}
+
+// 重命名目录
+func renameFolder(c *gin.Context) {
+	folder := loadFolder(c)
+	if folder == nil || !requireOwner(c, folder.OwnerID) {
+		return
+	}
+
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	request.Name = strings.TrimSpace(request.Name)
+	if !validEntryName(request.Name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的目录名"})
+		return
+	}
+	if request.Name == folder.Name {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": toFolderInfo(folder)})
+		return
+	}
+
+	taken, err := nameTaken(folder.SpaceID, folder.ParentID, request.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "同名文件或目录已存在"})
+		return
+	}
+
+	if err := folderRepo.Rename(folder.ID, request.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重命名目录失败"})
+		return
+	}
+	folder.Name = request.Name
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toFolderInfo(folder),
+		"message": "目录已重命名",
+	})
+}
+
+// 移动目录
+func moveFolder(c *gin.Context) {
+	folder := loadFolder(c)
+	if folder == nil || !requireOwner(c, folder.OwnerID) {
+		return
+	}
+
+	var request struct {
+		ParentID int64 `json:"parent_id"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+
+	// 不能移动到自己或自己的子目录下
+	chain, err := folderRepo.Ancestors(request.ParentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+		return
+	}
+	if request.ParentID != 0 && len(chain) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "目标目录不存在"})
+		return
+	}
+	if len(chain) > 0 && chain[0].SpaceID != folder.SpaceID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能跨空间移动目录"})
+		return
+	}
+	for _, ancestor := range chain {
+		if ancestor.ID == folder.ID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "不能移动到自身或子目录下"})
+			return
+		}
+	}
+
+	if request.ParentID != folder.ParentID {
+		taken, err := nameTaken(folder.SpaceID, request.ParentID, folder.Name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "目标目录下已有同名文件或目录"})
+			return
+		}
+		if err := folderRepo.Move(folder.ID, request.ParentID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "移动目录失败"})
+			return
+		}
+		folder.ParentID = request.ParentID
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toFolderInfo(folder),
+		"message": "目录已移动",
+	})
+}
+
+// 把目录连同其中的全部子目录和文件移入回收站
+func deleteFolder(c *gin.Context) {
+	folder := loadFolder(c)
+	if folder == nil {
+		return
+	}
+
+	tree, err := folderRepo.Subtree(folder.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+		return
+	}
+
+	// 先确认整棵子树都归当前用户管理，避免移到一半才发现无权限
+	var files []models.File
+	for _, f := range tree {
+		if !requireOwner(c, f.OwnerID) {
+			return
+		}
+		records, err := fileRepo.ListByFolder(f.SpaceID, f.ID, 0, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件失败"})
+			return
+		}
+		for i := range records {
+			if !requireOwner(c, records[i].OwnerID) {
+				return
+			}
+		}
+		files = append(files, records...)
+	}
+
+	item, err := trashFolder(int64(c.GetInt("userID")), folder, tree, files)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除目录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toTrashInfo(item),
+		"message": fmt.Sprintf("目录已移入回收站，共 %d 个子目录、%d 个文件", len(tree)-1, len(files)),
+	})
+}
+
+// 移动文件到其他目录
+func moveFile(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil || !requireOwner(c, record.OwnerID) {
+		return
+	}
+
+	var request struct {
+		FolderID int64  `json:"folder_id"`
+		Space    string `json:"space"` // 移动到某个空间的根目录时使用，默认当前空间
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+
+	spaceID := record.SpaceID
+	if request.FolderID != 0 {
+		target, err := folderRepo.GetByID(request.FolderID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+			return
+		}
+		if target == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "目标目录不存在"})
+			return
+		}
+		if !requireSpaceAccess(c, target.SpaceID) {
+			return
+		}
+		spaceID = target.SpaceID
+	} else if request.Space != "" {
+		space, ok := resolveSpace(c, request.Space)
+		if !ok {
+			return
+		}
+		spaceID = space.ID
+	}
+	if spaceID == record.SpaceID && request.FolderID == record.FolderID {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": toFileInfo(record)})
+		return
+	}
+
+	taken, err := nameTaken(spaceID, request.FolderID, record.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件记录失败"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "目标目录下已有同名文件或目录"})
+		return
+	}
+
+	// 内容在内容存储中不动，只改记录
+	record.SpaceID = spaceID
+	record.FolderID = request.FolderID
+	record.Path = storagePath(spaceID, request.FolderID, record.Name)
+	if err := fileRepo.Update(record); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toFileInfo(record),
+		"message": "文件已移动",
+	})
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 相册分享页面及其中图片地址的有效期
+const albumURLTTL = 2 * time.Hour
+
+// 相册分享的所有者能看到的文件，所有者账号已不存在时只剩其私人空间的文件
+func sharedAlbumFiles(album *models.Album) ([]models.File, error) {
+	private, err := spaceRepo.Ensure(models.SpacePrivate, album.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := authService.User(album.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	var familyID int64
+	if owner != nil {
+		family, err := spaceRepo.Ensure(models.SpaceFamily, owner.HouseholdID)
+		if err != nil {
+			return nil, err
+		}
+		familyID = family.ID
+	}
+	return albumFiles(album, familyID, private.ID)
+}
+
+// 把相册中的文件打包为 ZIP 下载，失败时已写好响应
+func streamAlbumArchive(c *gin.Context, album *models.Album) {
+	records, err := sharedAlbumFiles(album)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相册失败"})
+		return
+	}
+	used := map[string]bool{}
+	entries := make([]archiveEntry, 0, len(records))
+	for i := range records {
+		entries = append(entries, archiveEntry{Name: uniqueEntryName(used, records[i].Name), File: &records[i], Modified: records[i].UpdatedAt})
+	}
+	streamArchive(c, album.Name+".zip", entries)
+}
+
+// 相册页面中的一项
+type galleryItem struct {
+	Name     string
+	Thumb    string // 没有缩略图时为空
+	View     string
+	Download string
+}
+
+// 渲染只读的相册页面，sig 为页面中链接使用的签名参数
+func renderAlbumGallery(c *gin.Context, share *models.Share, album *models.Album, sig url.Values) {
+	records, err := sharedAlbumFiles(album)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相册失败"})
+		return
+	}
+	base := "/api/s/" + share.Token
+	query := sig.Encode()
+	items := make([]galleryItem, 0, len(records))
+	for i := range records {
+		link := fmt.Sprintf("%s/items/%d?%s", base, records[i].ID, query)
+		item := galleryItem{Name: records[i].Name, View: link, Download: link + "&download=1"}
+		// 缩略图还没生成好时返回原图
+		if isPreviewable(records[i].ContentType) {
+			item.Thumb = link + "&size=medium"
+		}
+		items = append(items, item)
+	}
+
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err = albumGalleryPage.Execute(c.Writer, gin.H{
+		"Name":        album.Name,
+		"Description": album.Description,
+		"Count":       len(items),
+		"Items":       items,
+		"ArchiveURL":  base + "/archive?" + query,
+		"ExpireTime":  share.ExpiresAt.Format("2006-01-02 15:04:05"),
+	})
+	if err != nil {
+		fmt.Printf("⚠️  渲染相册页面失败: %v\n", err)
+	}
+}
+
+// 加载签名地址对应的相册分享，失败时已写好响应
+func loadSignedAlbumShare(c *gin.Context) (*models.Share, *models.Album) {
+	token := c.Param("token")
+	if !verifyShareSignature(c, token) {
+		return nil, nil
+	}
+	share := loadLiveShare(c, token)
+	if share == nil {
+		return nil, nil
+	}
+	if !share.IsAlbum() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
+		return nil, nil
+	}
+	target, ok := loadShareTarget(c, share)
+	if !ok {
+		return nil, nil
+	}
+	return share, target.Album
+}
+
+// 相册分享中的单个文件：size 为 small、medium、large 时返回缩略图，否则返回原文件，
+// download=1 时作为附件下载。访问次数在打开相册页面时已计过，这里不再计数
+func serveAlbumItem(c *gin.Context) {
+	_, album := loadSignedAlbumShare(c)
+	if album == nil {
+		return
+	}
+	fileID, err := strconv.ParseInt(c.Param("fileId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件ID"})
+		return
+	}
+
+	records, err := sharedAlbumFiles(album)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相册失败"})
+		return
+	}
+	var record *models.File
+	for i := range records {
+		if records[i].ID == fileID {
+			record = &records[i]
+			break
+		}
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+
+	size := c.Query("size")
+	if size == "" {
+		serveBlob(c, record.Blob, record.Name, record.ContentType, record.UpdatedAt, c.Query("download") != "")
+		return
+	}
+	if _, ok := thumbnailSizes[size]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的缩略图尺寸，可选 small、medium、large"})
+		return
+	}
+	if !isPreviewable(record.ContentType) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该文件没有缩略图"})
+		return
+	}
+	preview, err := previewRepo.Get(record.Blob)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询缩略图失败"})
+		return
+	}
+	if preview == nil || preview.Status != models.PreviewReady {
+		serveBlob(c, record.Blob, record.Name, record.ContentType, record.UpdatedAt, false)
+		return
+	}
+	serveThumbnail(c, record, preview, size)
+}
+
+// 下载整个相册的 ZIP
+func downloadAlbumArchive(c *gin.Context) {
+	_, album := loadSignedAlbumShare(c)
+	if album == nil {
+		return
+	}
+	streamAlbumArchive(c, album)
+}
+
+var albumGalleryPage = template.Must(template.New("album-gallery").Parse(`
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>{{.Name}}</title>
+	<style>
+		body {
+			font-family: Arial, sans-serif;
+			max-width: 1100px;
+			margin: 40px auto;
+			padding: 0 20px;
+			background: #f5f5f5;
+			color: #333;
+		}
+		.header {
+			background: white;
+			padding: 20px 30px;
+			border-radius: 10px;
+			box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+			margin-bottom: 20px;
+		}
+		.header h2 { margin: 0 0 8px; }
+		.meta { color: #888; font-size: 14px; }
+		.download-all {
+			display: inline-block;
+			margin-top: 12px;
+			background: #1890ff;
+			color: white;
+			padding: 8px 18px;
+			border-radius: 6px;
+			text-decoration: none;
+		}
+		.download-all:hover { background: #40a9ff; }
+		.grid {
+			display: grid;
+			grid-template-columns: repeat(auto-fill, minmax(200px, 1fr));
+			gap: 12px;
+		}
+		.item {
+			background: white;
+			border-radius: 8px;
+			overflow: hidden;
+			box-shadow: 0 1px 4px rgba(0,0,0,0.08);
+		}
+		.item img {
+			display: block;
+			width: 100%;
+			height: 200px;
+			object-fit: cover;
+			background: #eee;
+		}
+		.item .file {
+			display: flex;
+			align-items: center;
+			justify-content: center;
+			height: 200px;
+			font-size: 48px;
+			background: #f0f8ff;
+			text-decoration: none;
+		}
+		.item .caption {
+			display: flex;
+			justify-content: space-between;
+			gap: 8px;
+			padding: 8px 10px;
+			font-size: 13px;
+		}
+		.item .caption span { overflow: hidden; text-overflow: ellipsis; white-space: nowrap; }
+		.item .caption a { color: #1890ff; text-decoration: none; white-space: nowrap; }
+	</style>
+</head>
+<body>
+	<div class="header">
+		<h2>🖼️ {{.Name}}</h2>
+		{{if .Description}}<p>{{.Description}}</p>{{end}}
+		<div class="meta">共 {{.Count}} 个文件 · 链接过期时间: {{.ExpireTime}}</div>
+		{{if .Count}}<a class="download-all" href="{{.ArchiveURL}}">⬇️ 全部下载</a>{{end}}
+	</div>
+
+	<div class="grid">
+		{{range .Items}}
+		<div class="item">
+			{{if .Thumb}}
+			<a href="{{.View}}" target="_blank"><img src="{{.Thumb}}" alt="{{.Name}}" loading="lazy"></a>
+			{{else}}
+			<a class="file" href="{{.View}}" target="_blank">📄</a>
+			{{end}}
+			<div class="caption">
+				<span title="{{.Name}}">{{.Name}}</span>
+				<a href="{{.Download}}">下载</a>
+			</div>
+		</div>
+		{{end}}
+	</div>
+</body>
+</html>
+`))
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"familydrive/handlers"
+	"familydrive/internal/auth"
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 默认家庭 ID，启动时收养的无主文件放入它的家庭空间
+var defaultHouseholdID int64
+
+// 初始化家庭：确保有默认家庭，引入家庭之前的账号、家庭空间和全家配额归入默认家庭
+func initHouseholds() error {
+	h, err := authService.EnsureDefaultHousehold()
+	if err != nil {
+		return err
+	}
+	defaultHouseholdID = h.ID
+	if err := spaceRepo.AdoptFamily(h.ID); err != nil {
+		return err
+	}
+	return quotaRepo.AdoptFamily(h.ID)
+}
+
+// 当前用户所属的家庭
+func householdID(c *gin.Context) int64 {
+	return c.GetInt64("householdID")
+}
+
+// 聊天处理器按当前用户的家庭隔离
+func chatHandler(h http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h.ServeHTTP(c.Writer, handlers.WithHousehold(c.Request, householdID(c)))
+	}
+}
+
+// 家庭信息结构体
+type HouseholdInfo struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"createdAt"`
+}
+
+func toHouseholdInfo(h *models.Household) HouseholdInfo {
+	return HouseholdInfo{ID: h.ID, Name: h.Name, CreatedAt: h.CreatedAt.Format(time.RFC3339)}
+}
+
+// 邀请信息结构体，邀请码和链接只在创建时返回
+type InvitationInfo struct {
+	ID        int64  `json:"id"`
+	Role      string `json:"role"`
+	MaxUses   int    `json:"maxUses"`
+	Uses      int    `json:"uses"`
+	CreatedAt string `json:"createdAt"`
+	ExpiresAt string `json:"expiresAt"`
+	Usable    bool   `json:"usable"`
+	Code      string `json:"code,omitempty"`
+	Link      string `json:"link,omitempty"`
+}
+
+func toInvitationInfo(i *models.Invitation, code string) InvitationInfo {
+	info := InvitationInfo{
+		ID:        i.ID,
+		Role:      i.Role,
+		MaxUses:   i.MaxUses,
+		Uses:      i.Uses,
+		CreatedAt: i.CreatedAt.Format(time.RFC3339),
+		ExpiresAt: i.ExpiresAt.Format(time.RFC3339),
+		Usable:    i.Usable(time.Now()),
+	}
+	if code != "" {
+		info.Code = code
+		info.Link = inviteURL(code)
+	}
+	return info
+}
+
+// 邀请链接，打开后是加入家庭的注册页
+func inviteURL(code string) string {
+	return publicURL() + "/api/join/" + code
+}
+
+// 当前家庭及其成员
+func getHousehold(c *gin.Context) {
+	h, err := authService.Household(householdID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询家庭失败"})
+		return
+	}
+	if h == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "家庭不存在"})
+		return
+	}
+	members, err := authService.Members(h.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询家庭失败"})
+		return
+	}
+	list := make([]gin.H, 0, len(members))
+	for i := range members {
+		list = append(list, userInfo(&members[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"household": toHouseholdInfo(h),
+			"members":   list,
+		},
+	})
+}
+
+// 修改当前家庭的名称（管理员），请求体 {"name": "..."}
+func renameHousehold(c *gin.Context) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	h, err := authService.RenameHousehold(householdID(c), request.Name)
+	if errors.Is(err, auth.ErrInvalidHouseholdName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil || h == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改家庭名称失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toHouseholdInfo(h),
+		"message": "家庭名称已更新",
+	})
+}
+
+// 创建新家庭（默认家庭的管理员），请求体 {"name": "爷爷奶奶家"}。
+// 返回一个一次性的管理员邀请，交给新家庭的第一位成员注册
+func createHousehold(c *gin.Context) {
+	if !isInstanceAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有默认家庭的管理员可以创建家庭"})
+		return
+	}
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	h, invitation, code, err := authService.CreateHousehold(int64(c.GetInt("userID")), request.Name)
+	if errors.Is(err, auth.ErrInvalidHouseholdName) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建家庭失败"})
+		return
+	}
+
+	fmt.Printf("🏠 %s 创建了家庭 %s (ID: %d)\n", c.GetString("username"), h.Name, h.ID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"household":  toHouseholdInfo(h),
+			"invitation": toInvitationInfo(invitation, code),
+		},
+		"message": "家庭已创建",
+	})
+}
+
+// 列出当前家庭的邀请（管理员）
+func listInvitations(c *gin.Context) {
+	invitations, err := authService.Invitations(householdID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询邀请失败"})
+		return
+	}
+	list := make([]InvitationInfo, 0, len(invitations))
+	for i := range invitations {
+		list = append(list, toInvitationInfo(&invitations[i], ""))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// 创建加入当前家庭的邀请（管理员），请求体 {"role": "member", "max_uses": 1, "expire_hours": 168}。
+// role 默认 member；max_uses 默认 1 即一次性邀请，0 表示过期前不限次数；expire_hours 默认 7 天
+func createInvitation(c *gin.Context) {
+	var request struct {
+		Role        string `json:"role"`
+		MaxUses     *int   `json:"max_uses"`
+		ExpireHours int    `json:"expire_hours"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	if request.Role == "" {
+		request.Role = auth.RoleMember
+	}
+	maxUses := 1
+	if request.MaxUses != nil {
+		maxUses = *request.MaxUses
+	}
+	if maxUses < 0 || request.ExpireHours < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的邀请设置"})
+		return
+	}
+
+	invitation, code, err := authService.CreateInvitation(householdID(c), int64(c.GetInt("userID")), request.Role,
+		maxUses, time.Duration(request.ExpireHours)*time.Hour)
+	if errors.Is(err, auth.ErrInvalidRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建邀请失败"})
+		return
+	}
+
+	fmt.Printf("✉️  %s 创建了邀请 (ID: %d, 角色: %s)\n", c.GetString("username"), invitation.ID, invitation.Role)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toInvitationInfo(invitation, code),
+		"message": "邀请已创建",
+	})
+}
+
+// 作废当前家庭的邀请（管理员）
+func revokeInvitation(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的邀请ID"})
+		return
+	}
+	ok, err := authService.RevokeInvitation(householdID(c), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "作废邀请失败"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "邀请不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "邀请已作废"})
+}
+
+// 查询邀请码（公开），客户端注册前用来显示要加入的家庭
+func checkInvitation(c *gin.Context) {
+	invitation, h, err := authService.Invitation(c.Param("code"))
+	if errors.Is(err, auth.ErrInvalidInvitation) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询邀请失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"household": h.Name,
+			"role":      invitation.Role,
+			"expiresAt": invitation.ExpiresAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// 邀请链接打开的注册页（公开）
+func joinHousehold(c *gin.Context) {
+	code := c.Param("code")
+	_, h, err := authService.Invitation(code)
+	if errors.Is(err, auth.ErrInvalidInvitation) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询邀请失败"})
+		return
+	}
+
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err = joinPage.Execute(c.Writer, gin.H{
+		"Household": h.Name,
+		"Code":      code,
+	})
+	if err != nil {
+		fmt.Printf("⚠️  渲染邀请页面失败: %v\n", err)
+	}
+}
+
+var joinPage = template.Must(template.New("join").Parse(`
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>加入 {{.Household}}</title>
+	<style>
+		body {
+			font-family: Arial, sans-serif;
+			max-width: 500px;
+			margin: 100px auto;
+			padding: 20px;
+			background: #f5f5f5;
+		}
+		.container {
+			background: white;
+			padding: 30px;
+			border-radius: 10px;
+			box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+		}
+		.form-group { margin-bottom: 16px; }
+		label { display: block; margin-bottom: 6px; color: #555; }
+		input {
+			width: 100%;
+			padding: 10px;
+			border: 1px solid #ddd;
+			border-radius: 5px;
+			box-sizing: border-box;
+		}
+		button {
+			width: 100%;
+			padding: 12px;
+			background: #007bff;
+			color: white;
+			border: none;
+			border-radius: 5px;
+			cursor: pointer;
+		}
+		.message { display: none; margin-top: 16px; padding: 10px; border-radius: 5px; }
+		.error { background: #f8d7da; color: #721c24; }
+		.success { background: #d4edda; color: #155724; }
+	</style>
+</head>
+<body>
+	<div class="container">
+		<h2>🏠 加入 {{.Household}}</h2>
+		<form id="joinForm">
+			<div class="form-group">
+				<label for="username">用户名:</label>
+				<input type="text" id="username" required>
+			</div>
+			<div class="form-group">
+				<label for="email">邮箱:</label>
+				<input type="email" id="email" required>
+			</div>
+			<div class="form-group">
+				<label for="password">密码:</label>
+				<input type="password" id="password" required minlength="6">
+			</div>
+			<button type="submit">注册并加入</button>
+		</form>
+		<div id="error" class="message error"></div>
+		<div id="success" class="message success"></div>
+	</div>
+
+	<script>
+		const inviteCode = {{.Code}};
+
+		document.getElementById('joinForm').addEventListener('submit', async function(e) {
+			e.preventDefault();
+			const errorDiv = document.getElementById('error');
+			const successDiv = document.getElementById('success');
+			errorDiv.style.display = 'none';
+			successDiv.style.display = 'none';
+
+			try {
+				const response = await fetch('/api/auth/register', {
+					method: 'POST',
+					headers: { 'Content-Type': 'application/json' },
+					body: JSON.stringify({
+						username: document.getElementById('username').value,
+						email: document.getElementById('email').value,
+						password: document.getElementById('password').value,
+						invite_code: inviteCode
+					})
+				});
+				const result = await response.json();
+				if (response.ok) {
+					successDiv.textContent = '✅ 注册成功，请在家庭网盘中登录';
+					successDiv.style.display = 'block';
+					document.getElementById('joinForm').style.display = 'none';
+				} else {
+					errorDiv.textContent = '❌ ' + (result.error || '注册失败');
+					errorDiv.style.display = 'block';
+				}
+			} catch (err) {
+				errorDiv.textContent = '❌ 网络错误，请重试';
+				errorDiv.style.display = 'block';
+			}
+		});
+	</script>
+</body>
+</html>
+`))
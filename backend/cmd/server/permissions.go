@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"familydrive/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 需要特别说明的路由所需的权限（键为“方法 路由”）。
+// 未列出的路由：GET、HEAD 需要 PermRead，其余方法需要 PermWrite，/api/admin 下的全部需要 PermAdmin
+var routePermissions = map[string]auth.Permission{
+	"POST /api/auth/logout":           auth.PermAccount,
+	"DELETE /api/sessions/:id":        auth.PermAccount,
+	"POST /api/files/archive":         auth.PermRead, // 打包下载
+	"POST /api/files/share/:filename": auth.PermShare,
+	"POST /api/folders/:id/share":     auth.PermShare,
+	"POST /api/albums/:id/share":      auth.PermShare,
+	"PATCH /api/shares/:token":        auth.PermShare,
+	"POST /api/chat/clear":            auth.PermClearChat,
+}
+
+// 请求所需的权限
+func requiredPermission(c *gin.Context) auth.Permission {
+	path := c.FullPath()
+	if strings.HasPrefix(path, "/api/admin/") {
+		return auth.PermAdmin
+	}
+	if p, ok := routePermissions[c.Request.Method+" "+path]; ok {
+		return p
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead:
+		return auth.PermRead
+	}
+	return auth.PermWrite
+}
+
+// 当前用户的角色是否拥有权限
+func can(c *gin.Context, p auth.Permission) bool {
+	return auth.Can(c.GetString("role"), p)
+}
+
+// 按角色的权限矩阵检查每个受保护的请求，需在 GinAuthMiddleware 之后使用
+func PermissionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := requiredPermission(c)
+		if !can(c, p) {
+			if p == auth.PermAdmin {
+				c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
+			} else {
+				c.JSON(http.StatusForbidden, gin.H{"error": "当前角色没有权限执行此操作"})
+			}
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// 账号是否为当前用户家庭中的孩子，查询失败时按否处理
+func isChild(c *gin.Context, userID int64) bool {
+	u, err := authService.User(userID)
+	return err == nil && u != nil && u.Role == auth.RoleChild && u.HouseholdID == householdID(c)
+}
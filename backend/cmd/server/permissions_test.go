@@ -0,0 +1,87 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"familydrive/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPermissionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api := router.Group("/api")
+	// 代替 GinAuthMiddleware，角色取自请求头
+	api.Use(func(c *gin.Context) { c.Set("role", c.GetHeader("X-Test-Role")) }, PermissionMiddleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	routes := []struct{ method, path string }{
+		{http.MethodGet, "/files/list"},
+		{http.MethodHead, "/files/:id/thumbnail"},
+		{http.MethodPost, "/files/archive"},
+		{http.MethodPost, "/files/upload"},
+		{http.MethodDelete, "/files/delete/:filename"},
+		{http.MethodPost, "/files/share/:filename"},
+		{http.MethodPatch, "/shares/:token"},
+		{http.MethodPost, "/chat/send"},
+		{http.MethodPost, "/chat/clear"},
+		{http.MethodPost, "/auth/logout"},
+		{http.MethodDelete, "/sessions/:id"},
+		{http.MethodGet, "/admin/users"},
+		{http.MethodPut, "/admin/users/:id/role"},
+	}
+	for _, r := range routes {
+		api.Handle(r.method, r.path, ok)
+	}
+
+	const (
+		A = auth.RoleAdmin
+		P = auth.RoleParent
+		M = auth.RoleMember
+		C = auth.RoleChild
+		G = auth.RoleGuest
+	)
+	tests := []struct {
+		method, path string
+		allowed      []string
+	}{
+		{http.MethodGet, "/api/files/list", []string{A, P, M, C, G}},
+		{http.MethodHead, "/api/files/1/thumbnail", []string{A, P, M, C, G}},
+		// 打包下载虽是 POST，只需读权限
+		{http.MethodPost, "/api/files/archive", []string{A, P, M, C, G}},
+		{http.MethodPost, "/api/files/upload", []string{A, P, M, C}},
+		{http.MethodDelete, "/api/files/delete/a.txt", []string{A, P, M, C}},
+		{http.MethodPost, "/api/chat/send", []string{A, P, M, C}},
+		{http.MethodPost, "/api/files/share/a.txt", []string{A, P, M}},
+		{http.MethodPatch, "/api/shares/abc", []string{A, P, M}},
+		{http.MethodPost, "/api/chat/clear", []string{A, P}},
+		// 访客也能管理自己的会话
+		{http.MethodPost, "/api/auth/logout", []string{A, P, M, C, G}},
+		{http.MethodDelete, "/api/sessions/abc", []string{A, P, M, C, G}},
+		// /api/admin 下的读写都只有管理员可以
+		{http.MethodGet, "/api/admin/users", []string{A}},
+		{http.MethodPut, "/api/admin/users/2/role", []string{A}},
+	}
+	for _, tt := range tests {
+		allowed := map[string]bool{}
+		for _, role := range tt.allowed {
+			allowed[role] = true
+		}
+		for _, role := range append(append([]string{}, auth.Roles...), "", "owner") {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-Test-Role", role)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			want := http.StatusForbidden
+			if allowed[role] {
+				want = http.StatusOK
+			}
+			if w.Code != want {
+				t.Errorf("%s %s as %q: status %d, want %d", tt.method, tt.path, role, w.Code, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"familydrive/internal/exif"
+	"familydrive/internal/models"
+	"familydrive/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 照片信息
+type PhotoInfo struct {
+	TakenAt     string   `json:"takenAt,omitempty"`
+	Camera      string   `json:"camera,omitempty"`
+	Orientation int      `json:"orientation"`
+	Width       int      `json:"width"`
+	Height      int      `json:"height"`
+	Latitude    *float64 `json:"latitude,omitempty"`
+	Longitude   *float64 `json:"longitude,omitempty"`
+}
+
+// 解析过的照片返回照片信息，其他文件返回 nil
+func toPhotoInfo(m *models.PhotoMeta) *PhotoInfo {
+	if m.Orientation == 0 {
+		return nil
+	}
+	info := &PhotoInfo{
+		Camera:      m.Camera,
+		Orientation: m.Orientation,
+		Width:       m.Width,
+		Height:      m.Height,
+		Latitude:    m.Latitude,
+		Longitude:   m.Longitude,
+	}
+	if m.TakenAt != nil {
+		info.TakenAt = m.TakenAt.In(time.Local).Format(time.RFC3339)
+	}
+	return info
+}
+
+// 读取 blob 中图片的尺寸和 EXIF 信息。图片本身无法解析时返回只有默认方向的信息，
+// 表示已经解析过；读取存储出错时返回错误
+func readPhotoMeta(hash string) (models.PhotoMeta, error) {
+	ctx := context.Background()
+	key := blobPath(hash)
+	info, err := store.Stat(ctx, key)
+	if err != nil {
+		return models.PhotoMeta{}, err
+	}
+	r := storage.NewReader(ctx, store, key, info.Size)
+	defer r.Close()
+
+	meta := models.PhotoMeta{Orientation: 1}
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return meta, nil
+	}
+	meta.Width, meta.Height = cfg.Width, cfg.Height
+	if format != "jpeg" {
+		return meta, nil
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return meta, err
+	}
+	data, err := exif.Decode(r, time.Local)
+	if err != nil {
+		return meta, nil
+	}
+
+	if data.Orientation > 0 {
+		meta.Orientation = data.Orientation
+	}
+	// 5-8 需要旋转 90 度，显示时宽高互换
+	if meta.Orientation >= 5 {
+		meta.Width, meta.Height = meta.Height, meta.Width
+	}
+	if !data.TakenAt.IsZero() {
+		meta.TakenAt = &data.TakenAt
+	}
+	meta.Camera = data.Camera()
+	if data.HasGPS {
+		meta.Latitude, meta.Longitude = &data.Latitude, &data.Longitude
+	}
+	return meta, nil
+}
+
+// 上传完成后解析照片信息；内容换成了其他类型的文件时清空原有信息
+func recordPhotoMeta(record *models.File) {
+	if !isPreviewable(record.ContentType) {
+		if record.Photo.Orientation != 0 {
+			record.Photo = models.PhotoMeta{}
+			if err := fileRepo.SetPhotoMeta(record.ID, record.Photo); err != nil {
+				fmt.Printf("⚠️  清除照片信息失败: %s: %v\n", record.Name, err)
+			}
+		}
+		return
+	}
+
+	meta, err := readPhotoMeta(record.Blob)
+	if err == nil {
+		err = fileRepo.SetPhotoMeta(record.ID, meta)
+	}
+	if err != nil {
+		// 留给下次启动时补读
+		fmt.Printf("⚠️  读取照片信息失败: %s: %v\n", record.Name, err)
+		return
+	}
+	record.Photo = meta
+}
+
+// 为引入照片信息之前上传的图片补读信息，需要旋转的照片重新生成缩略图。
+// 读取失败的照片（内容缺失、存储暂时不可用等）跳过，留给下次启动时再试
+func scanPhotos() {
+	scanned, failed := 0, 0
+	defer func() {
+		if scanned > 0 {
+			fmt.Printf("📷 已补读 %d 张照片的拍摄信息\n", scanned)
+		}
+		if failed > 0 {
+			fmt.Printf("⚠️  %d 张照片的拍摄信息读取失败，已跳过\n", failed)
+		}
+	}()
+	var lastID int64
+	for {
+		records, err := fileRepo.ListUnscannedPhotos(models.PreviewTypes, lastID, 100)
+		if err != nil {
+			fmt.Printf("⚠️  查询待解析的照片失败: %v\n", err)
+			return
+		}
+		if len(records) == 0 {
+			return
+		}
+		for i := range records {
+			rec := &records[i]
+			lastID = rec.ID
+			meta, err := readPhotoMeta(rec.Blob)
+			if err == nil {
+				err = fileRepo.SetPhotoMeta(rec.ID, meta)
+			}
+			if err == nil && meta.Orientation > 1 {
+				err = previewRepo.Requeue(rec.Blob)
+			}
+			if err != nil {
+				fmt.Printf("⚠️  读取照片信息失败: %s: %v\n", rec.Name, err)
+				failed++
+				continue
+			}
+			scanned++
+		}
+	}
+}
+
+// 时间线中的一天
+type timelineDay struct {
+	Date   string     `json:"date"`
+	Day    int        `json:"day"`
+	Count  int        `json:"count"`
+	Photos []FileInfo `json:"photos"`
+}
+
+type timelineMonth struct {
+	Month int            `json:"month"`
+	Count int            `json:"count"`
+	Days  []*timelineDay `json:"days"`
+}
+
+type timelineYear struct {
+	Year   int              `json:"year"`
+	Count  int              `json:"count"`
+	Months []*timelineMonth `json:"months"`
+}
+
+// 照片的拍摄时间，没有 EXIF 时间时按上传时间，统一换算到服务器时区分组
+func captureTime(f *models.File) time.Time {
+	if f.Photo.TakenAt != nil {
+		return f.Photo.TakenAt.In(time.Local)
+	}
+	return f.CreatedAt.In(time.Local)
+}
+
+// 照片时间线：按拍摄时间倒序，分年、月、日分组。
+// space 为 private 或 family 时只看该空间，默认两者都看；year、month 用于只看某年某月
+func photoTimeline(c *gin.Context) {
+	var year, month int
+	var err error
+	if v := c.Query("year"); v != "" {
+		if year, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的年份"})
+			return
+		}
+	}
+	if v := c.Query("month"); v != "" {
+		if month, err = strconv.Atoi(v); err != nil || month < 1 || month > 12 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的月份"})
+			return
+		}
+	}
+
+	spaceIDs, ok := visibleSpaceIDs(c)
+	if !ok {
+		return
+	}
+
+	records, err := fileRepo.ListPhotos(spaceIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询照片失败"})
+		return
+	}
+	photos := records[:0]
+	for i := range records {
+		t := captureTime(&records[i])
+		if (year == 0 || t.Year() == year) && (month == 0 || int(t.Month()) == month) {
+			photos = append(photos, records[i])
+		}
+	}
+	sort.SliceStable(photos, func(i, j int) bool {
+		ti, tj := captureTime(&photos[i]), captureTime(&photos[j])
+		if ti.Equal(tj) {
+			return photos[i].ID > photos[j].ID
+		}
+		return ti.After(tj)
+	})
+	infos, err := fileInfos(c, photos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询照片失败"})
+		return
+	}
+
+	years := []*timelineYear{}
+	var (
+		y *timelineYear
+		m *timelineMonth
+		d *timelineDay
+	)
+	for i := range photos {
+		t := captureTime(&photos[i])
+		if y == nil || y.Year != t.Year() {
+			y = &timelineYear{Year: t.Year()}
+			years = append(years, y)
+			m = nil
+		}
+		if m == nil || m.Month != int(t.Month()) {
+			m = &timelineMonth{Month: int(t.Month())}
+			y.Months = append(y.Months, m)
+			d = nil
+		}
+		if d == nil || d.Day != t.Day() {
+			d = &timelineDay{Date: t.Format("2006-01-02"), Day: t.Day()}
+			m.Days = append(m.Days, d)
+		}
+		d.Photos = append(d.Photos, infos[i])
+		y.Count++
+		m.Count++
+		d.Count++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"total": len(photos),
+			"years": years,
+		},
+	})
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 未单独设置时的默认配额（字节，0 表示不限），
+// 可通过 FAMILYDRIVE_USER_QUOTA 和 FAMILYDRIVE_FAMILY_QUOTA 配置
+var (
+	defaultUserQuota   int64
+	defaultFamilyQuota int64
+)
+
+// 用量信息结构体
+type UsageInfo struct {
+	Used     int64 `json:"used"`
+	Limit    int64 `json:"limit"`
+	Files    int64 `json:"files"`
+	Trash    int64 `json:"trash"`
+	Versions int64 `json:"versions"`
+	Pending  int64 `json:"pending"`
+}
+
+func toUsageInfo(u *models.Usage, limit int64) UsageInfo {
+	return UsageInfo{
+		Used:     u.Used(),
+		Limit:    limit,
+		Files:    u.Files,
+		Trash:    u.Trash,
+		Versions: u.Versions,
+		Pending:  u.Pending,
+	}
+}
+
+// 配额信息结构体
+type QuotaInfo struct {
+	Scope     string `json:"scope"`
+	SubjectID int64  `json:"subjectId"`
+	Limit     int64  `json:"limit"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// 读取默认配额配置
+func initQuota() {
+	if v := os.Getenv("FAMILYDRIVE_USER_QUOTA"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			defaultUserQuota = n
+		}
+	}
+	if v := os.Getenv("FAMILYDRIVE_FAMILY_QUOTA"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			defaultFamilyQuota = n
+		}
+	}
+}
+
+// 生效的配额上限：单独设置的优先，否则用默认值
+func quotaLimit(scope string, subjectID int64) (int64, error) {
+	q, err := quotaRepo.Get(scope, subjectID)
+	if err != nil {
+		return 0, err
+	}
+	if q != nil {
+		return q.LimitBytes, nil
+	}
+	if scope == models.QuotaFamily {
+		return defaultFamilyQuota, nil
+	}
+	return defaultUserQuota, nil
+}
+
+// 用户自己和所在家庭的用量与上限
+func loadUsage(userID int64) (user UsageInfo, family UsageInfo, err error) {
+	u, err := quotaRepo.UserUsage(userID)
+	if err != nil {
+		return
+	}
+	limit, err := quotaLimit(models.QuotaUser, userID)
+	if err != nil {
+		return
+	}
+	user = toUsageInfo(u, limit)
+
+	owner, err := authService.User(userID)
+	if err != nil {
+		return
+	}
+	var hid int64
+	if owner != nil {
+		hid = owner.HouseholdID
+	}
+	if u, err = householdUsage(hid); err != nil {
+		return
+	}
+	if limit, err = quotaLimit(models.QuotaFamily, hid); err != nil {
+		return
+	}
+	family = toUsageInfo(u, limit)
+	return
+}
+
+// 家庭的用量：全体成员名下的用量之和，默认家庭还包括收养的无主文件
+func householdUsage(hid int64) (*models.Usage, error) {
+	ids, err := householdOwnerIDs(hid)
+	if err != nil {
+		return nil, err
+	}
+	return quotaRepo.HouseholdUsage(ids)
+}
+
+// 家庭名下文件的所有者：全体成员，默认家庭还包括无主文件（所有者为 0）
+func householdOwnerIDs(hid int64) ([]int64, error) {
+	members, err := authService.Members(hid)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(members)+1)
+	for _, m := range members {
+		ids = append(ids, m.ID)
+	}
+	if hid == defaultHouseholdID {
+		ids = append(ids, 0)
+	}
+	return ids, nil
+}
+
+// 检查 userID 能否再写入 size 字节（预占的空间也算在内），
+// 单个文件就超过上限时返回 413，剩余空间不足时返回 507，失败时已写好响应
+func checkQuota(c *gin.Context, userID, size int64) bool {
+	user, family, err := loadUsage(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询配额失败"})
+		return false
+	}
+	for _, u := range []UsageInfo{user, family} {
+		if u.Limit <= 0 {
+			continue
+		}
+		if size > u.Limit {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "文件超过存储配额", "limit": u.Limit})
+			return false
+		}
+		if u.Used+u.Pending+size > u.Limit {
+			c.JSON(http.StatusInsufficientStorage, gin.H{
+				"error":     "存储空间不足",
+				"limit":     u.Limit,
+				"available": max(u.Limit-u.Used-u.Pending, 0),
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// userID 还能写入的字节数（扣除预占的空间），-1 表示不限
+func quotaAvailable(userID int64) (int64, error) {
+	user, family, err := loadUsage(userID)
+	if err != nil {
+		return 0, err
+	}
+	available := int64(-1)
+	for _, u := range []UsageInfo{user, family} {
+		if u.Limit <= 0 {
+			continue
+		}
+		left := max(u.Limit-u.Used-u.Pending, 0)
+		if available < 0 || left < available {
+			available = left
+		}
+	}
+	return available, nil
+}
+
+// 查询当前用户和所在家庭的存储用量
+func getUsage(c *gin.Context) {
+	user, family, err := loadUsage(int64(c.GetInt("userID")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用量失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"user": user, "family": family},
+	})
+}
+
+// 列出本家庭的配额设置（管理员）
+func listQuotas(c *gin.Context) {
+	quotas, err := quotaRepo.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询配额失败"})
+		return
+	}
+	members, err := authService.Members(householdID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询配额失败"})
+		return
+	}
+	memberIDs := map[int64]bool{}
+	for _, m := range members {
+		memberIDs[m.ID] = true
+	}
+
+	list := make([]QuotaInfo, 0, len(quotas))
+	for _, q := range quotas {
+		if q.Scope == models.QuotaFamily && q.SubjectID != householdID(c) || q.Scope == models.QuotaUser && !memberIDs[q.SubjectID] {
+			continue
+		}
+		list = append(list, QuotaInfo{
+			Scope:     q.Scope,
+			SubjectID: q.SubjectID,
+			Limit:     q.LimitBytes,
+			UpdatedAt: q.UpdatedAt.Format(time.RFC3339),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"defaults": gin.H{"user": defaultUserQuota, "family": defaultFamilyQuota},
+			"quotas":   list,
+		},
+	})
+}
+
+// 设置配额，请求体 {"limit": 字节数}，0 表示不限
+func saveQuota(c *gin.Context, scope string, subjectID int64) {
+	var request struct {
+		Limit *int64 `json:"limit"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || request.Limit == nil || *request.Limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的配额"})
+		return
+	}
+
+	q := &models.Quota{Scope: scope, SubjectID: subjectID, LimitBytes: *request.Limit}
+	if err := quotaRepo.Set(q); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存配额失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": QuotaInfo{
+			Scope:     q.Scope,
+			SubjectID: q.SubjectID,
+			Limit:     q.LimitBytes,
+			UpdatedAt: q.UpdatedAt.Format(time.RFC3339),
+		},
+		"message": "配额已更新",
+	})
+}
+
+// 删除单独设置的配额，恢复为默认值
+func resetQuota(c *gin.Context, scope string, subjectID int64) {
+	if err := quotaRepo.Delete(scope, subjectID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除配额失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "已恢复默认配额"})
+}
+
+// 路径参数 :id 指定的用户 ID，失败时已写好响应
+func paramUserID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// 路径参数 :id 指定的本家庭成员，失败时已写好响应
+func paramMemberID(c *gin.Context) (int64, bool) {
+	id, ok := paramUserID(c)
+	if !ok {
+		return 0, false
+	}
+	u, err := authService.User(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+		return 0, false
+	}
+	if u == nil || u.HouseholdID != householdID(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return 0, false
+	}
+	return id, true
+}
+
+// 设置本家庭成员的配额（管理员）
+func setUserQuota(c *gin.Context) {
+	if id, ok := paramMemberID(c); ok {
+		saveQuota(c, models.QuotaUser, id)
+	}
+}
+
+// 恢复本家庭成员的默认配额（管理员）
+func resetUserQuota(c *gin.Context) {
+	if id, ok := paramMemberID(c); ok {
+		resetQuota(c, models.QuotaUser, id)
+	}
+}
+
+// 设置本家庭的配额（管理员）
+func setFamilyQuota(c *gin.Context) {
+	saveQuota(c, models.QuotaFamily, householdID(c))
+}
+
+// 恢复本家庭的默认配额（管理员）
+func resetFamilyQuota(c *gin.Context) {
+	resetQuota(c, models.QuotaFamily, householdID(c))
+}
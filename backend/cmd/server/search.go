@@ -0,0 +1,382 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 内容命中的文档最多取这么多条，再和名称命中的合并
+const maxContentMatches = 1000
+
+// 搜索结果：文件信息加上得分和命中位置
+type SearchResult struct {
+	FileInfo
+	Score   float64  `json:"score"`
+	Matches []string `json:"matches"`           // name、content
+	Snippet string   `json:"snippet,omitempty"` // 内容命中时，命中处附近的一段文字
+}
+
+// 各分类包含的内容类型前缀，与前端的文件分类一致，其余归为 other
+var fileCategories = []struct {
+	name     string
+	prefixes []string
+}{
+	{"image", []string{"image/"}},
+	{"video", []string{"video/"}},
+	{"audio", []string{"audio/"}},
+	{"archive", []string{"application/zip", "application/x-zip", "application/x-7z", "application/gzip", "application/x-gzip",
+		"application/x-tar", "application/x-rar", "application/vnd.rar", "application/x-bzip2", "application/x-xz"}},
+	{"document", []string{"text/", "application/pdf", "application/msword", "application/rtf", "application/vnd.ms-",
+		"application/vnd.openxmlformats-officedocument.", "application/vnd.oasis.opendocument."}},
+}
+
+// 内容类型所属的分类
+func fileCategory(contentType string) string {
+	for _, cat := range fileCategories {
+		for _, p := range cat.prefixes {
+			if strings.HasPrefix(contentType, p) {
+				return cat.name
+			}
+		}
+	}
+	return "other"
+}
+
+// 按 type 参数过滤：分类名（image、video、audio、archive、document、other），
+// 或带 / 的内容类型前缀（如 image/png、application/）
+func matchType(contentType, want string) bool {
+	if strings.Contains(want, "/") {
+		return strings.HasPrefix(contentType, want)
+	}
+	return fileCategory(contentType) == want
+}
+
+// 解析日期参数：2006-01-02（按服务器时区）或 RFC3339，endOfDay 为 true 时日期取当天结束
+func parseDateParam(v string, endOfDay bool) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, true
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, true
+}
+
+// 编辑距离容错：四个字符以上的词允许错一个字，八个以上允许错两个
+func typoLimit(term string) int {
+	switch n := utf8.RuneCountInString(term); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+// pattern 与 text 中最相近的子串的编辑距离
+func substringDistance(text, pattern string) int {
+	t, p := []rune(text), []rune(pattern)
+	prev := make([]int, len(t)+1)
+	cur := make([]int, len(t)+1)
+	for i := 1; i <= len(p); i++ {
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if p[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	best := len(p)
+	for _, d := range prev {
+		best = min(best, d)
+	}
+	return best
+}
+
+// pattern 的字符按顺序出现在 text 中时，返回覆盖它们的最短片段长度，否则返回 0
+func subsequenceSpan(text, pattern string) int {
+	t, p := []rune(text), []rune(pattern)
+	if len(p) == 0 {
+		return 0
+	}
+	best := 0
+	for start := range t {
+		if t[start] != p[0] {
+			continue
+		}
+		k := 0
+		for j := start; j < len(t); j++ {
+			if t[j] == p[k] {
+				k++
+				if k == len(p) {
+					if span := j - start + 1; best == 0 || span < best {
+						best = span
+					}
+					break
+				}
+			}
+		}
+		if k < len(p) {
+			break
+		}
+	}
+	return best
+}
+
+// 文件名与查询的匹配得分，0 表示不匹配。query 为小写的完整查询，terms 为其中的各个词
+func nameScore(name, query string, terms []string) float64 {
+	n := strings.ToLower(name)
+	switch {
+	case n == query:
+		return 1
+	case strings.HasPrefix(n, query):
+		return 0.95
+	case strings.Contains(n, query):
+		return 0.9
+	}
+
+	all := true
+	for _, t := range terms {
+		if !strings.Contains(n, t) {
+			all = false
+			break
+		}
+	}
+	if all {
+		return 0.8
+	}
+
+	// 按顺序包含全部字符，如 "jtlx" 匹配 "jia-ting-lv-xing.jpg"
+	compact := strings.Join(terms, "")
+	if span := subsequenceSpan(n, compact); span > 0 && span <= 4*utf8.RuneCountInString(compact) {
+		return 0.4 + 0.3*float64(utf8.RuneCountInString(compact))/float64(span)
+	}
+
+	// 拼写错误：每个词都能在名称中找到足够接近的片段
+	typos := 0
+	for _, t := range terms {
+		if strings.Contains(n, t) {
+			continue
+		}
+		d := substringDistance(n, t)
+		if d > typoLimit(t) {
+			return 0
+		}
+		typos += d
+	}
+	return max(0.4-0.1*float64(typos), 0.1)
+}
+
+// 搜索文件：GET /api/search
+//
+//	q          按文件名（子串、按序缩写和拼写容错）和文档内容搜索，为空时只按条件过滤
+//	type       分类 image、video、audio、archive、document、other，或内容类型前缀如 image/png
+//	min_size   最小字节数
+//	max_size   最大字节数
+//	from, to   修改日期范围，2006-01-02 或 RFC3339
+//	owner      上传者 ID，me 表示自己
+//	space      private 或 family，默认两者都搜
+//	content    为 false 时不搜索文档内容
+//	tag        只搜带有这个标签的文件，可重复，需同时带有全部标签
+//	favorite   为 true 时只搜自己收藏的文件
+//	sort       relevance（有 q 时默认）、name、size、date（没有 q 时默认）；order 为 asc 或 desc
+//
+// 结果分页（page、page_size），总数同时通过 X-Total-Count 返回
+func searchFiles(c *gin.Context) {
+	filter := models.FileFilter{MaxSize: -1}
+	var err error
+	if v := c.Query("min_size"); v != "" {
+		if filter.MinSize, err = strconv.ParseInt(v, 10, 64); err != nil || filter.MinSize < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的最小大小"})
+			return
+		}
+	}
+	if v := c.Query("max_size"); v != "" {
+		if filter.MaxSize, err = strconv.ParseInt(v, 10, 64); err != nil || filter.MaxSize < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的最大大小"})
+			return
+		}
+	}
+	switch owner := c.Query("owner"); owner {
+	case "":
+	case "me":
+		filter.OwnerID = int64(c.GetInt("userID"))
+	default:
+		if filter.OwnerID, err = strconv.ParseInt(owner, 10, 64); err != nil || filter.OwnerID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的上传者"})
+			return
+		}
+	}
+	var from, to time.Time
+	if v := c.Query("from"); v != "" {
+		var ok bool
+		if from, ok = parseDateParam(v, false); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的开始日期"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		var ok bool
+		if to, ok = parseDateParam(v, true); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的结束日期"})
+			return
+		}
+	}
+	tagIDs, found, ok := tagFilter(c)
+	if !ok {
+		return
+	}
+	if !found {
+		c.Header("X-Total-Count", "0")
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"total": 0, "results": []SearchResult{}}})
+		return
+	}
+	filter.TagIDs = tagIDs
+	if c.Query("favorite") == "true" {
+		filter.Favorite = int64(c.GetInt("userID"))
+	}
+	fileType := strings.ToLower(c.Query("type"))
+	if fileType == "all" {
+		fileType = ""
+	}
+
+	query := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	terms := strings.Fields(query)
+	query = strings.Join(terms, " ")
+	sortBy := c.Query("sort")
+	if sortBy == "" {
+		sortBy = "date"
+		if query != "" {
+			sortBy = "relevance"
+		}
+	}
+	if sortBy != "relevance" && sortBy != "name" && sortBy != "size" && sortBy != "date" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的排序方式，可选 relevance、name、size、date"})
+		return
+	}
+
+	spaceIDs, ok := visibleSpaceIDs(c)
+	if !ok {
+		return
+	}
+	filter.SpaceIDs = spaceIDs
+	records, err := fileRepo.Search(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+		return
+	}
+
+	snippets := map[string]string{}
+	if query != "" && c.Query("content") != "false" {
+		if snippets, err = contentRepo.Match(terms, spaceIDs, maxContentMatches); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索文档内容失败"})
+			return
+		}
+	}
+
+	type hit struct {
+		record  *models.File
+		score   float64
+		matches []string
+		snippet string
+	}
+	hits := []hit{}
+	for i := range records {
+		rec := &records[i]
+		if fileType != "" && !matchType(rec.ContentType, fileType) {
+			continue
+		}
+		if (!from.IsZero() && rec.UpdatedAt.Before(from)) || (!to.IsZero() && rec.UpdatedAt.After(to)) {
+			continue
+		}
+		h := hit{record: rec, matches: []string{}}
+		if query != "" {
+			if s := nameScore(rec.Name, query, terms); s > 0 {
+				h.score = s
+				h.matches = append(h.matches, "name")
+			}
+			if snippet, ok := snippets[rec.Blob]; ok && rec.Blob != "" {
+				// 只有内容命中的排在名称命中的后面
+				h.score = max(h.score+0.1, 0.5)
+				h.matches = append(h.matches, "content")
+				h.snippet = snippet
+			}
+			if len(h.matches) == 0 {
+				continue
+			}
+		}
+		hits = append(hits, h)
+	}
+
+	desc := c.DefaultQuery("order", "desc") != "asc"
+	if sortBy == "name" {
+		desc = c.Query("order") == "desc"
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := hits[i], hits[j]
+		switch sortBy {
+		case "relevance":
+			if a.score != b.score {
+				return a.score > b.score
+			}
+			return a.record.UpdatedAt.After(b.record.UpdatedAt)
+		case "name":
+			if desc {
+				return a.record.Name > b.record.Name
+			}
+			return a.record.Name < b.record.Name
+		case "size":
+			if desc {
+				return a.record.Size > b.record.Size
+			}
+			return a.record.Size < b.record.Size
+		}
+		if desc {
+			return a.record.UpdatedAt.After(b.record.UpdatedAt)
+		}
+		return a.record.UpdatedAt.Before(b.record.UpdatedAt)
+	})
+
+	page, size := pageParams(c)
+	total := len(hits)
+	start := min((page-1)*size, total)
+	hits = hits[start:min(start+size, total)]
+
+	pageRecords := make([]models.File, len(hits))
+	for i, h := range hits {
+		pageRecords[i] = *h.record
+	}
+	infos, err := fileInfos(c, pageRecords)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索失败"})
+		return
+	}
+	results := make([]SearchResult, len(hits))
+	for i, h := range hits {
+		results[i] = SearchResult{FileInfo: infos[i], Score: h.score, Matches: h.matches, Snippet: h.snippet}
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"total":   total,
+			"results": results,
+		},
+	})
+}
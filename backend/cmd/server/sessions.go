@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 会话（登录设备）信息结构体
+type SessionInfo struct {
+	ID         string `json:"id"`
+	DeviceName string `json:"deviceName"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"userAgent"`
+	CreatedAt  string `json:"createdAt"`
+	LastSeenAt string `json:"lastSeenAt"`
+	ExpiresAt  string `json:"expiresAt"`
+	Current    bool   `json:"current"` // 是否为发起请求的这个会话
+}
+
+func toSessionInfo(s *models.Session, current string) SessionInfo {
+	return SessionInfo{
+		ID:         s.ID,
+		DeviceName: s.DeviceName,
+		IP:         s.IP,
+		UserAgent:  s.UserAgent,
+		CreatedAt:  s.CreatedAt.Format(time.RFC3339),
+		LastSeenAt: s.LastSeenAt.Format(time.RFC3339),
+		ExpiresAt:  s.ExpiresAt.Format(time.RFC3339),
+		Current:    s.ID == current,
+	}
+}
+
+// 列出当前用户已登录的设备，最近活动的在前
+func listSessions(c *gin.Context) {
+	sessions, err := authService.Sessions(int64(c.GetInt("userID")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询会话失败"})
+		return
+	}
+	current := c.GetString("sessionID")
+	list := make([]SessionInfo, 0, len(sessions))
+	for i := range sessions {
+		list = append(list, toSessionInfo(&sessions[i], current))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// 远程下线某台设备，如丢失的手机；下线当前会话等同于登出
+func revokeSession(c *gin.Context) {
+	ok, err := authService.RevokeSession(int64(c.GetInt("userID")), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "下线设备失败"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在或已失效"})
+		return
+	}
+
+	fmt.Printf("📵 已下线会话: 用户 %d\n", c.GetInt("userID"))
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "设备已下线"})
+}
@@ -0,0 +1,682 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"familydrive/internal/models"
+	apimodels "familydrive/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 分享链接、邀请链接的对外地址前缀，可通过 FAMILYDRIVE_PUBLIC_URL 覆盖
+func publicURL() string {
+	base := os.Getenv("FAMILYDRIVE_PUBLIC_URL")
+	if base == "" {
+		base = "https://localhost:8000"
+	}
+	return base
+}
+
+// 分享链接地址
+func shareURL(token string) string {
+	return fmt.Sprintf("%s/api/s/%s", publicURL(), token)
+}
+
+// 生成分享 token：16 位十六进制随机串
+func newShareToken() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// 加密分享密码
+func hashSharePassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// 验证分享密码
+func verifySharePassword(inputPassword, storedHash string) bool {
+	if storedHash == "" {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(storedHash), []byte(inputPassword)) == nil
+}
+
+// 查找分享对应的文件记录，文件或内容已不存在时返回 nil
+func resolveShareFile(share *models.Share) (*models.File, error) {
+	var record *models.File
+	var err error
+	if share.FileID != 0 {
+		record, err = fileRepo.GetByID(share.FileID)
+	} else {
+		record, err = fileRepo.GetByPath(share.Filename)
+	}
+	if err != nil || record == nil {
+		return nil, err
+	}
+
+	ok, err := blobExists(record.Blob)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return record, nil
+}
+
+// 查找目录分享对应的目录，已删除时返回 nil
+func resolveShareFolder(share *models.Share) (*models.Folder, error) {
+	return folderRepo.GetByID(share.FolderID)
+}
+
+// 分享指向的对象：文件、目录、相册三者之一非 nil
+type shareTarget struct {
+	File   *models.File
+	Folder *models.Folder
+	Album  *models.Album
+}
+
+// 加载分享指向的文件、目录或相册，不存在时已写好响应
+func loadShareTarget(c *gin.Context, share *models.Share) (*shareTarget, bool) {
+	switch {
+	case share.IsAlbum():
+		album, err := albumRepo.GetByID(share.AlbumID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询相册失败"})
+			return nil, false
+		}
+		if album == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "相册不存在"})
+			return nil, false
+		}
+		return &shareTarget{Album: album}, true
+
+	case share.IsFolder():
+		folder, err := resolveShareFolder(share)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+			return nil, false
+		}
+		if folder == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "目录不存在"})
+			return nil, false
+		}
+		return &shareTarget{Folder: folder}, true
+	}
+
+	record, err := resolveShareFile(share)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件记录失败"})
+		return nil, false
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return nil, false
+	}
+	return &shareTarget{File: record}, true
+}
+
+// 加载未撤销、未过期的分享链接，失败时已写好响应
+func loadLiveShare(c *gin.Context, token string) *models.Share {
+	share, err := shareRepo.GetByToken(token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询分享失败"})
+		return nil
+	}
+	if share == nil || !share.IsActive {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分享链接不存在或已失效"})
+		return nil
+	}
+
+	// 检查是否过期
+	if share.IsExpired() {
+		c.JSON(http.StatusGone, gin.H{"error": "分享链接已过期"})
+		return nil
+	}
+	return share
+}
+
+// 加载并校验分享链接（含访问次数），失败时已写好响应
+func loadActiveShare(c *gin.Context, token string) *models.Share {
+	share := loadLiveShare(c, token)
+	if share == nil {
+		return nil
+	}
+
+	// 检查访问次数
+	if share.IsExhausted() {
+		c.JSON(http.StatusGone, gin.H{"error": "分享链接访问次数已用完"})
+		return nil
+	}
+	return share
+}
+
+// 计一次访问，失败时已写好响应
+func consumeShareAccess(c *gin.Context, share *models.Share) bool {
+	ok, err := shareRepo.ConsumeAccess(share.Token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新访问次数失败"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusGone, gin.H{"error": "分享链接访问次数已用完"})
+		return false
+	}
+	return true
+}
+
+// 计一次访问并发送分享文件。每个请求都计数，不管是否带 Range；
+// 续传、拖动进度应使用 downloadSharedFile 发放的签名地址，只在发放时计一次
+func serveSharedFile(c *gin.Context, share *models.Share, record *models.File) {
+	if !consumeShareAccess(c, share) {
+		return
+	}
+	serveBlob(c, record.Blob, record.Name, record.ContentType, record.UpdatedAt, true)
+}
+
+// 计一次访问并把整个目录打包为 ZIP 下载
+func serveSharedFolder(c *gin.Context, share *models.Share, folder *models.Folder) {
+	if consumeShareAccess(c, share) {
+		streamFolderArchive(c, folder)
+	}
+}
+
+// 把目录打包为 ZIP 下载，失败时已写好响应
+func streamFolderArchive(c *gin.Context, folder *models.Folder) {
+	entries, err := folderEntries(folder, folder.Name+"/")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询目录失败"})
+		return
+	}
+	streamArchive(c, folder.Name+".zip", entries)
+}
+
+// 创建分享链接
+func createShare(c *gin.Context) {
+	// 检查文件是否存在（支持 folder_id / path 指定目录）
+	record := lookupFile(c, c.Param("filename"))
+	if record == nil || !requireOwner(c, record.OwnerID) {
+		return
+	}
+	saveShare(c, &models.Share{FileID: record.ID, Filename: record.Name})
+}
+
+// 创建目录分享链接，访问时把整个目录打包下载
+func createFolderShare(c *gin.Context) {
+	folder := loadFolder(c)
+	if folder == nil || !requireOwner(c, folder.OwnerID) {
+		return
+	}
+	saveShare(c, &models.Share{FolderID: folder.ID, Filename: folder.Name})
+}
+
+// 按请求中的有效期、访问次数上限和密码保存分享
+func saveShare(c *gin.Context, share *models.Share) {
+	var request struct {
+		ExpireHours int    `json:"expire_hours"`
+		MaxAccess   int    `json:"max_access"`
+		Password    string `json:"password"`
+	}
+
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	if request.ExpireHours < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "有效期不能为负数"})
+		return
+	}
+	if request.MaxAccess < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "访问次数上限不能为负数"})
+		return
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成分享token失败"})
+		return
+	}
+	passwordHash, err := hashSharePassword(request.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+		return
+	}
+	expireTime := time.Now().Add(time.Duration(request.ExpireHours) * time.Hour)
+
+	// 保存分享记录
+	share.Token = token
+	share.PasswordHash = passwordHash
+	share.ExpiresAt = expireTime
+	share.MaxAccess = request.MaxAccess
+	share.CreatedBy = int64(c.GetInt("userID"))
+	if err := shareRepo.Create(share); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存分享记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"share_url":    shareURL(token),
+			"expire_time":  expireTime.Format(time.RFC3339),
+			"max_access":   request.MaxAccess,
+			"has_password": request.Password != "",
+			"token":        token,
+		},
+		"message": "分享链接创建成功",
+	})
+}
+
+// 通过分享链接访问文件
+func accessSharedFile(c *gin.Context) {
+	token := c.Param("token")
+
+	share := loadActiveShare(c, token)
+	if share == nil {
+		return
+	}
+	target, ok := loadShareTarget(c, share)
+	if !ok {
+		return
+	}
+
+	// 如果有密码，返回需要密码的页面
+	if share.PasswordHash != "" {
+		renderSharePasswordPage(c, share)
+		return
+	}
+
+	// 如果没有密码，直接下载并更新访问次数；目录打包为 ZIP 全部下载，相册显示相册页面
+	switch {
+	case target.Album != nil:
+		if consumeShareAccess(c, share) {
+			renderAlbumGallery(c, share, target.Album, signShare(share.Token, albumURLTTL))
+		}
+	case target.Folder != nil:
+		serveSharedFolder(c, share, target.Folder)
+	default:
+		serveSharedFile(c, share, target.File)
+	}
+}
+
+// 验证分享密码，通过后计一次访问并返回短期有效的下载地址。
+// 浏览器直接打开该地址下载，可以续传，视频也可以拖动进度
+func downloadSharedFile(c *gin.Context) {
+	var request struct {
+		Password string `json:"password"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	share := authorizeShare(c, c.Param("token"), "", request.Password)
+	if share == nil {
+		return
+	}
+	if _, ok := loadShareTarget(c, share); !ok || !consumeShareAccess(c, share) {
+		return
+	}
+
+	// 相册页面要浏览一阵，地址有效期长一些
+	ttl := signedURLTTL
+	if share.IsAlbum() {
+		ttl = albumURLTTL
+	}
+	downloadURL, expiresAt := signedShareURL(share.Token, ttl)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"url":       downloadURL,
+			"expiresAt": expiresAt.Format(time.RFC3339),
+		},
+		"message": "密码验证成功",
+	})
+}
+
+// 安全下载文件（旧接口，按文件名 + 分享 token + 密码）
+func secureDownloadFile(c *gin.Context) {
+	var request struct {
+		Password   string `json:"password"`
+		ShareToken string `json:"share_token"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	share := authorizeShare(c, request.ShareToken, c.Param("filename"), request.Password)
+	if share == nil {
+		return
+	}
+	target, ok := loadShareTarget(c, share)
+	if !ok {
+		return
+	}
+	switch {
+	case target.Album != nil:
+		if consumeShareAccess(c, share) {
+			streamAlbumArchive(c, target.Album)
+		}
+	case target.Folder != nil:
+		serveSharedFolder(c, share, target.Folder)
+	default:
+		serveSharedFile(c, share, target.File)
+	}
+}
+
+// 校验分享链接、文件名和密码，失败时已写好响应
+func authorizeShare(c *gin.Context, token, filename, password string) *models.Share {
+	share := loadActiveShare(c, token)
+	if share == nil {
+		return nil
+	}
+
+	// 验证文件名匹配
+	if filename != "" && share.Filename != filename {
+		c.JSON(http.StatusForbidden, gin.H{"error": "文件不匹配"})
+		return nil
+	}
+
+	// 验证密码
+	if !verifySharePassword(password, share.PasswordHash) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "密码错误"})
+		return nil
+	}
+	return share
+}
+
+// 转换为分享列表的响应结构
+func toShareLinkResponse(share *models.Share) apimodels.ShareLinkResponse {
+	return apimodels.ShareLinkResponse{
+		ID:          share.Token,
+		Filename:    share.Filename,
+		ShareURL:    shareURL(share.Token),
+		CreatedAt:   share.CreatedAt,
+		ExpiresAt:   share.ExpiresAt,
+		AccessCount: share.AccessCount,
+		MaxAccess:   share.MaxAccess,
+		IsProtected: share.PasswordHash != "",
+		IsFolder:    share.IsFolder(),
+		IsAlbum:     share.IsAlbum(),
+	}
+}
+
+// 加载当前用户创建的分享，失败时已写好响应
+func loadOwnedShare(c *gin.Context) *models.Share {
+	share, err := shareRepo.GetByToken(c.Param("token"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询分享失败"})
+		return nil
+	}
+	if share == nil || !share.IsActive {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分享链接不存在或已失效"})
+		return nil
+	}
+	if share.CreatedBy != int64(c.GetInt("userID")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权管理该分享"})
+		return nil
+	}
+	return share
+}
+
+// 列出我的分享链接
+func listShares(c *gin.Context) {
+	shares, err := shareRepo.ListByCreator(int64(c.GetInt("userID")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询分享失败"})
+		return
+	}
+
+	list := make([]apimodels.ShareLinkResponse, 0, len(shares))
+	for i := range shares {
+		list = append(list, toShareLinkResponse(&shares[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    list,
+	})
+}
+
+// 修改分享：有效期、访问次数上限、密码（空字符串表示取消密码）
+func updateShare(c *gin.Context) {
+	share := loadOwnedShare(c)
+	if share == nil {
+		return
+	}
+
+	var request struct {
+		ExpireHours *int       `json:"expire_hours"`
+		ExpiresAt   *time.Time `json:"expires_at"`
+		MaxAccess   *int       `json:"max_access"`
+		Password    *string    `json:"password"`
+	}
+	if err := c.BindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+
+	switch {
+	case request.ExpiresAt != nil:
+		share.ExpiresAt = *request.ExpiresAt
+	case request.ExpireHours != nil:
+		if *request.ExpireHours < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "有效期不能为负数"})
+			return
+		}
+		share.ExpiresAt = time.Now().Add(time.Duration(*request.ExpireHours) * time.Hour)
+	}
+	if request.MaxAccess != nil {
+		if *request.MaxAccess < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "访问次数上限不能为负数"})
+			return
+		}
+		share.MaxAccess = *request.MaxAccess
+	}
+	if request.Password != nil {
+		hash, err := hashSharePassword(*request.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "密码加密失败"})
+			return
+		}
+		share.PasswordHash = hash
+	}
+
+	if err := shareRepo.Update(share); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新分享失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toShareLinkResponse(share),
+		"message": "分享已更新",
+	})
+}
+
+// 撤销分享
+func revokeShare(c *gin.Context) {
+	share := loadOwnedShare(c)
+	if share == nil {
+		return
+	}
+
+	if err := shareRepo.Revoke(share.Token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "撤销分享失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "分享已撤销",
+	})
+}
+
+var sharePasswordPage = template.Must(template.New("share-password").Parse(`
+<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<title>下载受密码保护的文件</title>
+	<style>
+		body {
+			font-family: Arial, sans-serif;
+			max-width: 500px;
+			margin: 100px auto;
+			padding: 20px;
+			background: #f5f5f5;
+		}
+		.container {
+			background: white;
+			padding: 30px;
+			border-radius: 10px;
+			box-shadow: 0 2px 10px rgba(0,0,0,0.1);
+		}
+		.form-group { margin-bottom: 20px; }
+		label { display: block; margin-bottom: 8px; font-weight: bold; color: #333; }
+		input {
+			width: 100%;
+			padding: 12px;
+			border: 1px solid #ddd;
+			border-radius: 6px;
+			font-size: 16px;
+			box-sizing: border-box;
+		}
+		button {
+			background: #1890ff;
+			color: white;
+			padding: 12px 24px;
+			border: none;
+			border-radius: 6px;
+			cursor: pointer;
+			font-size: 16px;
+			width: 100%;
+		}
+		button:hover { background: #40a9ff; }
+		.error {
+			color: #ff4d4f;
+			margin-top: 10px;
+			padding: 10px;
+			background: #fff2f0;
+			border: 1px solid #ffccc7;
+			border-radius: 6px;
+			display: none;
+		}
+		.success {
+			color: #52c41a;
+			margin-top: 10px;
+			padding: 10px;
+			background: #f6ffed;
+			border: 1px solid #b7eb8f;
+			border-radius: 6px;
+			display: none;
+		}
+		.file-info {
+			background: #f0f8ff;
+			padding: 15px;
+			border-radius: 6px;
+			margin-bottom: 20px;
+			border-left: 4px solid #1890ff;
+		}
+	</style>
+</head>
+<body>
+	<div class="container">
+		<h2>🔒 受密码保护的文件下载</h2>
+
+		<div class="file-info">
+			<strong>文件名称:</strong> {{.Filename}}<br>
+			<strong>剩余访问次数:</strong> {{.Remaining}}<br>
+			<strong>链接过期时间:</strong> {{.ExpireTime}}
+		</div>
+
+		<form id="downloadForm">
+			<div class="form-group">
+				<label for="password">访问密码:</label>
+				<input type="password" id="password" name="password" required placeholder="请输入访问密码">
+			</div>
+			<button type="submit">🔐 验证并下载</button>
+		</form>
+
+		<div id="error" class="error"></div>
+		<div id="success" class="success"></div>
+	</div>
+
+	<script>
+		const downloadURL = {{.DownloadURL}};
+
+		document.getElementById('downloadForm').addEventListener('submit', async function(e) {
+			e.preventDefault();
+			const password = document.getElementById('password').value;
+			const errorDiv = document.getElementById('error');
+			const successDiv = document.getElementById('success');
+
+			// 隐藏消息
+			errorDiv.style.display = 'none';
+			successDiv.style.display = 'none';
+
+			if (!password) {
+				errorDiv.textContent = '请输入访问密码';
+				errorDiv.style.display = 'block';
+				return;
+			}
+
+			try {
+				const response = await fetch(downloadURL, {
+					method: 'POST',
+					headers: { 'Content-Type': 'application/json' },
+					body: JSON.stringify({ password: password })
+				});
+
+				if (response.ok) {
+					// 跳转到短期下载地址，由浏览器自己下载（支持断点续传）
+					const result = await response.json();
+					window.location.href = result.data.url;
+
+					successDiv.textContent = '✅ 验证成功！文件已开始下载';
+					successDiv.style.display = 'block';
+				} else {
+					const errorData = await response.json();
+					errorDiv.textContent = '❌ ' + (errorData.error || '下载失败');
+					errorDiv.style.display = 'block';
+				}
+			} catch (err) {
+				errorDiv.textContent = '❌ 网络错误，请重试';
+				errorDiv.style.display = 'block';
+			}
+		});
+	</script>
+</body>
+</html>
+`))
+
+// 渲染分享密码输入页
+func renderSharePasswordPage(c *gin.Context, share *models.Share) {
+	remaining := "不限"
+	if share.MaxAccess > 0 {
+		remaining = strconv.Itoa(share.MaxAccess-share.AccessCount) + "/" + strconv.Itoa(share.MaxAccess)
+	}
+
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	err := sharePasswordPage.Execute(c.Writer, gin.H{
+		"Filename":    share.Filename,
+		"Remaining":   remaining,
+		"ExpireTime":  share.ExpiresAt.Format("2006-01-02 15:04:05"),
+		"DownloadURL": "/api/s/" + share.Token + "/download",
+	})
+	if err != nil {
+		fmt.Printf("⚠️  渲染分享页面失败: %v\n", err)
+	}
+}
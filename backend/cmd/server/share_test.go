@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	appdb "familydrive/internal/db"
+	"familydrive/internal/models"
+	"familydrive/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 在临时目录中准备数据库和本地存储
+func setupShareTest(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := appdb.Init(filepath.Join(dir, "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(appdb.Close)
+	fileRepo = models.NewFileRepository(appdb.DB())
+	shareRepo = models.NewShareRepository(appdb.DB())
+
+	local, err := storage.NewLocal(filepath.Join(dir, "uploads"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStore := store
+	store = local
+	t.Cleanup(func() { store = oldStore })
+}
+
+func TestSharedFileRangeCountsAccess(t *testing.T) {
+	setupShareTest(t)
+	body := []byte("hello, family drive")
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	if err := store.Put(context.Background(), blobPath(hash), bytes.NewReader(body), int64(len(body))); err != nil {
+		t.Fatal(err)
+	}
+	record := &models.File{Name: "a.txt", Path: "a.txt", Size: int64(len(body)), Blob: hash}
+	if err := fileRepo.Create(record); err != nil {
+		t.Fatal(err)
+	}
+	share := &models.Share{Token: "tok", FileID: record.ID, Filename: "a.txt", ExpiresAt: time.Now().Add(time.Hour), MaxAccess: 1}
+	if err := shareRepo.Create(share); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/s/:token", accessSharedFile)
+	get := func(rangeHeader, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/s/tok", nil)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := get("bytes=7-11", ""); w.Code != http.StatusPartialContent || w.Body.String() != "famil" {
+		t.Fatalf("first range: status %d body %q", w.Code, w.Body.String())
+	}
+	// 从中间开始的 Range 请求和条件请求同样计数，次数用完后都被拒绝
+	if w := get("bytes=12-", ""); w.Code != http.StatusGone {
+		t.Errorf("second range: status %d, want %d", w.Code, http.StatusGone)
+	}
+	if w := get("", blobETag(hash)); w.Code != http.StatusGone {
+		t.Errorf("conditional request: status %d, want %d", w.Code, http.StatusGone)
+	}
+	got, err := shareRepo.GetByToken("tok")
+	if err != nil || got.AccessCount != 1 {
+		t.Errorf("access count: %+v, %v", got, err)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"familydrive/internal/auth"
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 空间信息结构体
+type SpaceInfo struct {
+	ID   int64  `json:"id"`
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+func toSpaceInfo(s *models.Space) SpaceInfo {
+	name := "我的文件"
+	if s.Kind == models.SpaceFamily {
+		name = "家庭共享"
+	}
+	return SpaceInfo{ID: s.ID, Kind: s.Kind, Name: name}
+}
+
+// 当前用户的私人空间
+func privateSpace(c *gin.Context) (*models.Space, error) {
+	return spaceRepo.Ensure(models.SpacePrivate, int64(c.GetInt("userID")))
+}
+
+// 当前用户家庭的共享空间
+func familySpace(c *gin.Context) (*models.Space, error) {
+	return spaceRepo.Ensure(models.SpaceFamily, householdID(c))
+}
+
+// 按名称选择空间：private（默认）、family，或空间 ID（家长访问孩子的私人空间），失败时已写好响应
+func resolveSpace(c *gin.Context, kind string) (*models.Space, bool) {
+	var (
+		space *models.Space
+		err   error
+	)
+	switch kind {
+	case "", models.SpacePrivate:
+		space, err = privateSpace(c)
+	case models.SpaceFamily:
+		space, err = familySpace(c)
+	default:
+		id, perr := strconv.ParseInt(kind, 10, 64)
+		if perr != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的空间"})
+			return nil, false
+		}
+		if !requireSpaceAccess(c, id) {
+			return nil, false
+		}
+		space, err = spaceRepo.GetByID(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+		return nil, false
+	}
+	return space, true
+}
+
+// 从查询参数或表单字段 space 选择空间，失败时已写好响应
+func spaceFromRequest(c *gin.Context) (*models.Space, bool) {
+	kind := c.Query("space")
+	if kind == "" {
+		kind = c.Request.FormValue("space")
+	}
+	return resolveSpace(c, kind)
+}
+
+// 当前用户可访问的空间：space 为 private 或 family 时只取该空间，否则两者都取，失败时已写好响应
+func visibleSpaceIDs(c *gin.Context) ([]int64, bool) {
+	if kind := c.Query("space"); kind != "" {
+		space, ok := resolveSpace(c, kind)
+		if !ok {
+			return nil, false
+		}
+		return []int64{space.ID}, true
+	}
+	private, err := privateSpace(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+		return nil, false
+	}
+	family, err := familySpace(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+		return nil, false
+	}
+	return []int64{private.ID, family.ID}, true
+}
+
+// 检查当前用户能否访问空间：私人空间仅限本人和管理孩子的家长，家庭空间对本家庭的成员开放
+func canAccessSpace(c *gin.Context, spaceID int64) (bool, error) {
+	space, err := spaceRepo.GetByID(spaceID)
+	if err != nil || space == nil {
+		return false, err
+	}
+	if space.Kind == models.SpaceFamily {
+		return space.OwnerID == householdID(c), nil
+	}
+	if space.OwnerID == int64(c.GetInt("userID")) {
+		return true, nil
+	}
+	return can(c, auth.PermManageChildren) && isChild(c, space.OwnerID), nil
+}
+
+// 要求能访问空间，失败时已写好响应
+func requireSpaceAccess(c *gin.Context, spaceID int64) bool {
+	ok, err := canAccessSpace(c, spaceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权访问"})
+		return false
+	}
+	return true
+}
+
+// 当前用户能否管理（删除、分享、移动）某个条目：
+// 所有者和管理孩子的家长可以，启动时收养的无主文件（OwnerID 为 0）家庭成员都可以处理
+func canManage(c *gin.Context, ownerID int64) bool {
+	if ownerID == 0 || ownerID == int64(c.GetInt("userID")) {
+		return true
+	}
+	return can(c, auth.PermManageChildren) && isChild(c, ownerID)
+}
+
+// 要求是条目的所有者，失败时已写好响应
+func requireOwner(c *gin.Context, ownerID int64) bool {
+	if !canManage(c, ownerID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有所有者可以执行此操作"})
+		return false
+	}
+	return true
+}
+
+// 列出当前用户可用的空间，家长还能看到本家庭孩子们的私人空间
+func listSpaces(c *gin.Context) {
+	private, err := privateSpace(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+		return
+	}
+	family, err := familySpace(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+		return
+	}
+	list := []SpaceInfo{toSpaceInfo(private), toSpaceInfo(family)}
+
+	if can(c, auth.PermManageChildren) {
+		users, err := authService.Members(householdID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+			return
+		}
+		for _, u := range users {
+			if u.Role != auth.RoleChild {
+				continue
+			}
+			space, err := spaceRepo.Ensure(models.SpacePrivate, u.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "查询空间失败"})
+				return
+			}
+			info := toSpaceInfo(space)
+			info.Name = u.Username + " 的文件"
+			list = append(list, info)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    list,
+	})
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"familydrive/internal/storage"
+)
+
+// 文件内容的存储后端，由 FAMILYDRIVE_STORAGE 选择：
+// local（默认）存放在上传目录，s3 存放在 S3 兼容的对象存储（MinIO、NAS 等），
+// 连接参数见 initStorage。临时文件和断点续传的分片始终在本地上传目录
+var (
+	store         storage.Storage
+	storageDriver = "local"
+)
+
+// 读取存储配置并连接存储后端
+func initStorage() error {
+	if v := os.Getenv("FAMILYDRIVE_UPLOAD_DIR"); v != "" {
+		uploadDir = v
+	}
+	if v := os.Getenv("FAMILYDRIVE_STORAGE"); v != "" {
+		storageDriver = v
+	}
+
+	var err error
+	store, err = storage.Open(storage.Config{
+		Driver:    storageDriver,
+		Root:      uploadDir,
+		Endpoint:  os.Getenv("FAMILYDRIVE_S3_ENDPOINT"),
+		Region:    os.Getenv("FAMILYDRIVE_S3_REGION"),
+		Bucket:    os.Getenv("FAMILYDRIVE_S3_BUCKET"),
+		Prefix:    os.Getenv("FAMILYDRIVE_S3_PREFIX"),
+		AccessKey: os.Getenv("FAMILYDRIVE_S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("FAMILYDRIVE_S3_SECRET_KEY"),
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Println("✅ 存储后端:", storageDriver)
+	return migrateLocalBlobs()
+}
+
+// 从本地存储切换到其他后端后，把上传目录里已有的 blob 搬过去
+func migrateLocalBlobs() error {
+	if _, ok := store.(*storage.Local); ok {
+		return nil
+	}
+	local, err := storage.NewLocal(uploadDir)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	objects, err := local.List(ctx, "blobs/")
+	if err != nil {
+		return err
+	}
+
+	for _, o := range objects {
+		r, err := local.Get(ctx, o.Key, 0, -1)
+		if err != nil {
+			return err
+		}
+		err = store.Put(ctx, o.Key, r, o.Size)
+		r.Close()
+		if err != nil {
+			return err
+		}
+		if err := local.Delete(ctx, o.Key); err != nil {
+			return err
+		}
+	}
+	if len(objects) > 0 {
+		fmt.Printf("☁️  已把 %d 个 blob 搬到存储后端 %s\n", len(objects), storageDriver)
+	}
+	return nil
+}
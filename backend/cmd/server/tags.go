@@ -0,0 +1,484 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxTagName       = 32   // 标签名的最大字符数
+	maxMetadataKey   = 64   // 键的最大字符数
+	maxMetadataValue = 1000 // 值的最大字符数
+	maxMetadataKeys  = 50   // 每个文件最多的键数
+)
+
+// 标签信息结构体
+type TagInfo struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+	Count     int    `json:"count"`
+	CreatedAt string `json:"createdAt"`
+}
+
+func toTagInfo(t *models.Tag) TagInfo {
+	return TagInfo{
+		ID:        t.ID,
+		Name:      t.Name,
+		Color:     t.Color,
+		Count:     t.Count,
+		CreatedAt: t.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// 整理请求中的标签名：去掉首尾空白，忽略空的，不区分大小写去重；有过长的名称时返回 false
+func tagNames(names []string) ([]string, bool) {
+	seen := map[string]bool{}
+	list := []string{}
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" || seen[strings.ToLower(n)] {
+			continue
+		}
+		if utf8.RuneCountInString(n) > maxTagName {
+			return nil, false
+		}
+		seen[strings.ToLower(n)] = true
+		list = append(list, n)
+	}
+	return list, true
+}
+
+// 颜色为空或 #rrggbb
+func validTagColor(color string) bool {
+	if color == "" {
+		return true
+	}
+	if len(color) != 7 || color[0] != '#' {
+		return false
+	}
+	_, err := strconv.ParseUint(color[1:], 16, 32)
+	return err == nil
+}
+
+// 读取请求中的 tag 参数（可重复），返回当前用户对应的标签 ID。
+// found 为 false 表示有标签不存在，没有文件能符合条件；失败时已写好响应
+func tagFilter(c *gin.Context) (ids []int64, found bool, ok bool) {
+	names, valid := tagNames(c.QueryArray("tag"))
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的标签"})
+		return nil, false, false
+	}
+	if len(names) == 0 {
+		return nil, true, true
+	}
+	ids, found, err := tagRepo.Lookup(int64(c.GetInt("userID")), names)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询标签失败"})
+		return nil, false, false
+	}
+	return ids, found, true
+}
+
+// 加载路径参数 :id 指定的、当前用户自己的标签，失败时已写好响应
+func loadTag(c *gin.Context) *models.Tag {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的标签ID"})
+		return nil
+	}
+	tag, err := tagRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询标签失败"})
+		return nil
+	}
+	// 别人的标签当作不存在
+	if tag == nil || tag.OwnerID != int64(c.GetInt("userID")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return nil
+	}
+	return tag
+}
+
+// 列出当前用户的标签及各自的文件数
+func listTags(c *gin.Context) {
+	tags, err := tagRepo.ListByOwner(int64(c.GetInt("userID")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询标签失败"})
+		return
+	}
+	list := make([]TagInfo, 0, len(tags))
+	for i := range tags {
+		list = append(list, toTagInfo(&tags[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// 创建标签：{"name": "...", "color": "#rrggbb"}
+func createTag(c *gin.Context) {
+	var request struct {
+		Name  string `json:"name"`
+		Color string `json:"color"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	names, ok := tagNames([]string{request.Name})
+	if !ok || len(names) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("标签名不能为空，且不超过 %d 个字", maxTagName)})
+		return
+	}
+	if !validTagColor(request.Color) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "颜色格式应为 #rrggbb"})
+		return
+	}
+
+	userID := int64(c.GetInt("userID"))
+	existing, err := tagRepo.GetByName(userID, names[0])
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询标签失败"})
+		return
+	}
+	if existing != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "同名标签已存在"})
+		return
+	}
+	tag := &models.Tag{OwnerID: userID, Name: names[0], Color: request.Color}
+	if err := tagRepo.Create(tag); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建标签失败"})
+		return
+	}
+
+	fmt.Printf("🏷️  创建标签: %s\n", tag.Name)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data":    toTagInfo(tag),
+		"message": "标签创建成功",
+	})
+}
+
+// 修改标签名称或颜色
+func updateTag(c *gin.Context) {
+	tag := loadTag(c)
+	if tag == nil {
+		return
+	}
+	var request struct {
+		Name  *string `json:"name"`
+		Color *string `json:"color"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+
+	if request.Name != nil {
+		names, ok := tagNames([]string{*request.Name})
+		if !ok || len(names) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("标签名不能为空，且不超过 %d 个字", maxTagName)})
+			return
+		}
+		existing, err := tagRepo.GetByName(tag.OwnerID, names[0])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询标签失败"})
+			return
+		}
+		if existing != nil && existing.ID != tag.ID {
+			c.JSON(http.StatusConflict, gin.H{"error": "同名标签已存在"})
+			return
+		}
+		tag.Name = names[0]
+	}
+	if request.Color != nil {
+		if !validTagColor(*request.Color) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "颜色格式应为 #rrggbb"})
+			return
+		}
+		tag.Color = *request.Color
+	}
+
+	if err := tagRepo.Update(tag); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新标签失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toTagInfo(tag),
+		"message": "标签已更新",
+	})
+}
+
+// 删除标签，文件本身不受影响
+func deleteTag(c *gin.Context) {
+	tag := loadTag(c)
+	if tag == nil {
+		return
+	}
+	if err := tagRepo.Delete(tag.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除标签失败"})
+		return
+	}
+
+	fmt.Printf("🗑️  删除标签: %s\n", tag.Name)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "标签已删除"})
+}
+
+// 返回单个文件的最新信息（含标签、收藏和键值）
+func respondFile(c *gin.Context, record *models.File, message string) {
+	infos, err := fileInfos(c, []models.File{*record})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件记录失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    infos[0],
+		"message": message,
+	})
+}
+
+// 设置当前用户在文件上的标签：{"tags": ["2024 报税", ...]}，不存在的标签自动创建
+func setFileTags(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil {
+		return
+	}
+	var request struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	names, ok := tagNames(request.Tags)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("标签名不能超过 %d 个字", maxTagName)})
+		return
+	}
+
+	userID := int64(c.GetInt("userID"))
+	tags, err := tagRepo.Ensure(userID, names)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建标签失败"})
+		return
+	}
+	ids := make([]int64, len(tags))
+	for i := range tags {
+		ids[i] = tags[i].ID
+	}
+	if err := tagRepo.SetFileTags(userID, record.ID, ids); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新标签失败"})
+		return
+	}
+	respondFile(c, record, "标签已更新")
+}
+
+// 批量打标签：{"file_ids": [...], "add": ["..."], "remove": ["..."]}，
+// add 中不存在的标签自动创建，remove 中不存在的忽略
+func bulkTagFiles(c *gin.Context) {
+	var request struct {
+		FileIDs []int64  `json:"file_ids"`
+		Add     []string `json:"add"`
+		Remove  []string `json:"remove"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil || len(request.FileIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请选择文件"})
+		return
+	}
+	add, ok := tagNames(request.Add)
+	remove, ok2 := tagNames(request.Remove)
+	if !ok || !ok2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("标签名不能超过 %d 个字", maxTagName)})
+		return
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请指定要添加或移除的标签"})
+		return
+	}
+
+	for _, id := range request.FileIDs {
+		record, err := fileRepo.GetByID(id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件记录失败"})
+			return
+		}
+		if record == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在", "fileId": id})
+			return
+		}
+		if !requireSpaceAccess(c, record.SpaceID) {
+			return
+		}
+	}
+
+	userID := int64(c.GetInt("userID"))
+	var removeIDs []int64
+	for _, name := range remove {
+		tag, err := tagRepo.GetByName(userID, name)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "查询标签失败"})
+			return
+		}
+		if tag != nil {
+			removeIDs = append(removeIDs, tag.ID)
+		}
+	}
+	if err := tagRepo.Untag(removeIDs, request.FileIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新标签失败"})
+		return
+	}
+	tags, err := tagRepo.Ensure(userID, add)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建标签失败"})
+		return
+	}
+	addIDs := make([]int64, len(tags))
+	for i := range tags {
+		addIDs[i] = tags[i].ID
+	}
+	if err := tagRepo.Tag(addIDs, request.FileIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新标签失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"updated": len(request.FileIDs)},
+		"message": fmt.Sprintf("已更新 %d 个文件的标签", len(request.FileIDs)),
+	})
+}
+
+// 列出当前用户收藏的文件，最近修改的在前；space=private|family 只看其一
+func listFavorites(c *gin.Context) {
+	spaceIDs, ok := visibleSpaceIDs(c)
+	if !ok {
+		return
+	}
+	records, err := fileRepo.Search(models.FileFilter{
+		SpaceIDs: spaceIDs,
+		MaxSize:  -1,
+		Favorite: int64(c.GetInt("userID")),
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询收藏失败"})
+		return
+	}
+	list, err := fileInfos(c, records)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询收藏失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// 收藏文件
+func addFavorite(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil {
+		return
+	}
+	if err := favoriteRepo.Add(int64(c.GetInt("userID")), record.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "收藏失败"})
+		return
+	}
+	respondFile(c, record, "已收藏")
+}
+
+// 取消收藏
+func removeFavorite(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil {
+		return
+	}
+	if err := favoriteRepo.Remove(int64(c.GetInt("userID")), record.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "取消收藏失败"})
+		return
+	}
+	respondFile(c, record, "已取消收藏")
+}
+
+// 文件上的自定义键值
+func getFileMetadata(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil {
+		return
+	}
+	metadata, err := metadataRepo.Get(record.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询键值失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": metadata})
+}
+
+// 修改文件上的键值：{"票据号": "2024-031", "旧键": null}，值为 null 的键被删除，
+// 未列出的键保持不变。只有文件的所有者可以修改
+func updateFileMetadata(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil || !requireOwner(c, record.OwnerID) {
+		return
+	}
+	var request map[string]*string
+	if err := c.ShouldBindJSON(&request); err != nil || len(request) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+	values := make(map[string]*string, len(request))
+	for key, value := range request {
+		key = strings.TrimSpace(key)
+		if key == "" || utf8.RuneCountInString(key) > maxMetadataKey {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("键不能为空，且不超过 %d 个字", maxMetadataKey)})
+			return
+		}
+		if value != nil && utf8.RuneCountInString(*value) > maxMetadataValue {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("值不能超过 %d 个字", maxMetadataValue), "key": key})
+			return
+		}
+		values[key] = value
+	}
+
+	current, err := metadataRepo.Get(record.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询键值失败"})
+		return
+	}
+	for key, value := range values {
+		if value == nil {
+			delete(current, key)
+		} else {
+			current[key] = *value
+		}
+	}
+	if len(current) > maxMetadataKeys {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("每个文件最多 %d 个键", maxMetadataKeys)})
+		return
+	}
+
+	if err := metadataRepo.Update(record.ID, values); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新键值失败"})
+		return
+	}
+	respondFile(c, record, "键值已更新")
+}
+
+// 删除文件上的一个键
+func deleteFileMetadata(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil || !requireOwner(c, record.OwnerID) {
+		return
+	}
+	if err := metadataRepo.Update(record.ID, map[string]*string{c.Param("key"): nil}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新键值失败"})
+		return
+	}
+	respondFile(c, record, "键值已删除")
+}
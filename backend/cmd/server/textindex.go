@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"familydrive/internal/fulltext"
+	"familydrive/internal/models"
+	"familydrive/internal/storage"
+)
+
+// 后台每批提取的数量
+const textIndexBatch = 20
+
+// 有新的待索引文档时唤醒后台任务
+var textIndexWake = make(chan struct{}, 1)
+
+// 上传完成后登记待提取文本的文档
+func enqueueTextIndex(record *models.File) {
+	kind := fulltext.KindOf(record.ContentType, record.Name)
+	if record.Blob == "" || kind == "" {
+		return
+	}
+	added, err := contentRepo.Enqueue(record.Blob, kind)
+	if err != nil {
+		fmt.Printf("⚠️  登记全文索引失败: %s: %v\n", record.Name, err)
+		return
+	}
+	if added {
+		select {
+		case textIndexWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 为 blob 提取文本并写入全文索引。文档本身无法解析时标记为失败，不再重试；
+// 读取存储出错时返回错误，留到下一轮
+func indexText(item models.ContentIndex) error {
+	ctx := context.Background()
+	key := blobPath(item.Blob)
+	info, err := store.Stat(ctx, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return contentRepo.Delete(item.Blob)
+	}
+	if err != nil {
+		return err
+	}
+
+	r := storage.NewReader(ctx, store, key, info.Size)
+	text, err := fulltext.Extract(item.Kind, r, info.Size)
+	r.Close()
+	if errors.Is(err, fulltext.ErrUnsupported) {
+		fmt.Printf("⚠️  无法提取文本: %s\n", item.Blob)
+		return contentRepo.MarkFailed(item.Blob)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 与 releaseBlob 互斥，避免为刚删除的内容留下索引
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	blob, err := blobRepo.Get(item.Blob)
+	if err != nil {
+		return err
+	}
+	if blob == nil {
+		return contentRepo.Delete(item.Blob)
+	}
+	return contentRepo.SetText(item.Blob, text)
+}
+
+// 为引入全文搜索之前上传的文档补登记，返回登记条数
+func backfillTextIndex() (int, error) {
+	records, err := fileRepo.List()
+	if err != nil {
+		return 0, err
+	}
+	blobs := map[string]string{}
+	for i := range records {
+		if kind := fulltext.KindOf(records[i].ContentType, records[i].Name); kind != "" && records[i].Blob != "" {
+			blobs[records[i].Blob] = kind
+		}
+	}
+	return contentRepo.EnqueueAll(blobs)
+}
+
+// 后台建立全文索引：启动时补登记已有的文档，之后有新上传时立即处理，
+// 另外每隔 interval 检查一次，重试上次因存储出错没有完成的
+func startTextIndexer(interval time.Duration) {
+	go func() {
+		if n, err := backfillTextIndex(); err != nil {
+			fmt.Printf("⚠️  登记已有文档的全文索引失败: %v\n", err)
+		} else if n > 0 {
+			fmt.Printf("🔎 %d 个已有文档等待建立全文索引\n", n)
+		}
+
+		for {
+			processTextIndex()
+			select {
+			case <-textIndexWake:
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// 处理全部待索引的文档，遇到存储错误时停止本轮
+func processTextIndex() {
+	for {
+		pending, err := contentRepo.ListPending(textIndexBatch)
+		if err != nil {
+			fmt.Printf("⚠️  查询待索引的文档失败: %v\n", err)
+			return
+		}
+		if len(pending) == 0 {
+			return
+		}
+		for _, item := range pending {
+			if err := indexText(item); err != nil {
+				fmt.Printf("⚠️  建立全文索引失败: %s: %v\n", item.Blob, err)
+				return
+			}
+		}
+	}
+}
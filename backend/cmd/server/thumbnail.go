@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"familydrive/internal/exif"
+	"familydrive/internal/models"
+	"familydrive/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 缩略图尺寸：长边的像素数，原图更小时不放大
+var thumbnailSizes = map[string]int{
+	"small":  160,
+	"medium": 480,
+	"large":  1280,
+}
+
+// 从大到小生成，小图由上一级缩放得到
+var thumbnailOrder = []string{"large", "medium", "small"}
+
+const (
+	// 超过这个像素数的图片不生成缩略图，避免解码时占满树莓派的内存
+	thumbnailMaxPixels = 50_000_000
+	// 后台每批处理的数量
+	thumbnailBatch = 20
+)
+
+// 有新的待生成缩略图时唤醒后台任务
+var thumbnailWake = make(chan struct{}, 1)
+
+// 内容类型是否可以生成缩略图
+func isPreviewable(contentType string) bool {
+	for _, t := range models.PreviewTypes {
+		if contentType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// 缩略图在存储中的 key，同一内容的缩略图放在一起
+func thumbnailPath(hash, size, format string) string {
+	return path.Join("thumbs", hash[:2], hash, size+"."+format)
+}
+
+// 上传完成后登记待生成的缩略图
+func enqueuePreview(record *models.File) {
+	if record.Blob == "" || !isPreviewable(record.ContentType) {
+		return
+	}
+	added, err := previewRepo.Enqueue(record.Blob)
+	if err != nil {
+		fmt.Printf("⚠️  登记缩略图失败: %s: %v\n", record.Name, err)
+		return
+	}
+	if added {
+		select {
+		case thumbnailWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// 删除 blob 的缩略图，调用方持有 blobMu
+func deletePreview(hash string) error {
+	ctx := context.Background()
+	for _, format := range []string{"jpeg", "png"} {
+		for size := range thumbnailSizes {
+			if err := store.Delete(ctx, thumbnailPath(hash, size, format)); err != nil {
+				return err
+			}
+		}
+	}
+	return previewRepo.Delete(hash)
+}
+
+// 缩略图生成结果：各尺寸编码后的内容
+type renderedPreview struct {
+	Format string
+	Width  int
+	Height int
+	Images map[string][]byte
+}
+
+// 解码图片并生成各尺寸的缩略图，返回的错误说明这张图片无法处理
+func renderPreview(r io.ReadSeeker) (*renderedPreview, error) {
+	cfg, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > thumbnailMaxPixels {
+		return nil, fmt.Errorf("图片尺寸 %dx%d 超出限制", cfg.Width, cfg.Height)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	// 手机照片常按拍摄时的姿势保存，靠 EXIF 方向在显示时旋转
+	orientation := 1
+	if format == "jpeg" {
+		if data, err := exif.Decode(r, time.Local); err == nil && data.Orientation > 0 {
+			orientation = data.Orientation
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// 照片用 JPEG，PNG、GIF 可能带透明通道，保持 PNG
+	result := &renderedPreview{Format: "jpeg", Width: cfg.Width, Height: cfg.Height, Images: map[string][]byte{}}
+	if format != "jpeg" {
+		result.Format = "png"
+	}
+	for i, size := range thumbnailOrder {
+		src = downscale(src, thumbnailSizes[size])
+		// 缩到最大一档后再旋转，小图由它缩放得到
+		if i == 0 {
+			src = orient(src, orientation)
+		}
+		var buf bytes.Buffer
+		if result.Format == "jpeg" {
+			err = jpeg.Encode(&buf, src, &jpeg.Options{Quality: 80})
+		} else {
+			err = png.Encode(&buf, src)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Images[size] = buf.Bytes()
+	}
+	return result, nil
+}
+
+// 按区域平均把 src 缩小到长边不超过 limit，已经够小时原样返回
+func downscale(src image.Image, limit int) image.Image {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw <= limit && sh <= limit {
+		return src
+	}
+	dw, dh := limit, limit
+	if sw >= sh {
+		dh = max(1, sh*limit/sw)
+	} else {
+		dw = max(1, sw*limit/sh)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for dy := 0; dy < dh; dy++ {
+		y0, y1 := b.Min.Y+dy*sh/dh, b.Min.Y+(dy+1)*sh/dh
+		for dx := 0; dx < dw; dx++ {
+			x0, x1 := b.Min.X+dx*sw/dw, b.Min.X+(dx+1)*sw/dw
+			var r, g, bl, a, n uint64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					cr, cg, cb, ca := src.At(x, y).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(dx, dy, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(bl / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+// 按 EXIF 方向（2-8）翻转、旋转图片，1 或未知时原样返回
+func orient(src image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转 180 度
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿主对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转 90 度
+				dx, dy = h-1-y, x
+			case 7: // 沿副对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转 90 度
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// 为 blob 生成缩略图。图片本身无法处理时标记为失败，不再重试；
+// 读写存储出错时返回错误，留到下一轮
+func generatePreview(hash string) error {
+	ctx := context.Background()
+	key := blobPath(hash)
+	info, err := store.Stat(ctx, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		// 内容已被删除
+		return previewRepo.Delete(hash)
+	}
+	if err != nil {
+		return err
+	}
+
+	r := storage.NewReader(ctx, store, key, info.Size)
+	result, err := renderPreview(r)
+	r.Close()
+	if err != nil {
+		fmt.Printf("⚠️  无法生成缩略图: %s: %v\n", hash, err)
+		return previewRepo.MarkFailed(hash)
+	}
+
+	// 与 releaseBlob 互斥，避免为刚删除的内容留下缩略图
+	blobMu.Lock()
+	defer blobMu.Unlock()
+	blob, err := blobRepo.Get(hash)
+	if err != nil {
+		return err
+	}
+	if blob == nil {
+		return previewRepo.Delete(hash)
+	}
+	for size, data := range result.Images {
+		if err := store.Put(ctx, thumbnailPath(hash, size, result.Format), bytes.NewReader(data), int64(len(data))); err != nil {
+			return err
+		}
+	}
+	return previewRepo.MarkReady(hash, result.Format, result.Width, result.Height)
+}
+
+// 后台生成缩略图：启动时为已有的图片补读照片信息、补登记缩略图，之后有新上传时立即处理，
+// 另外每隔 interval 检查一次，重试上次因存储出错没有完成的
+func startThumbnailWorker(interval time.Duration) {
+	go func() {
+		scanPhotos()
+		if n, err := previewRepo.Backfill(); err != nil {
+			fmt.Printf("⚠️  登记已有图片的缩略图失败: %v\n", err)
+		} else if n > 0 {
+			fmt.Printf("🖼️  %d 张已有图片等待生成缩略图\n", n)
+		}
+
+		for {
+			processPreviews()
+			select {
+			case <-thumbnailWake:
+			case <-time.After(interval):
+			}
+		}
+	}()
+}
+
+// 处理全部待生成的缩略图，遇到存储错误时停止本轮
+func processPreviews() {
+	for {
+		pending, err := previewRepo.ListPending(thumbnailBatch)
+		if err != nil {
+			fmt.Printf("⚠️  查询待生成的缩略图失败: %v\n", err)
+			return
+		}
+		if len(pending) == 0 {
+			return
+		}
+		for _, p := range pending {
+			if err := generatePreview(p.Blob); err != nil {
+				fmt.Printf("⚠️  生成缩略图失败: %s: %v\n", p.Blob, err)
+				return
+			}
+		}
+	}
+}
+
+// 获取图片的缩略图：size 为 small、medium（默认）或 large。
+// 还在生成时返回 202，无法生成时返回 404
+func getThumbnail(c *gin.Context) {
+	size := c.DefaultQuery("size", "medium")
+	if _, ok := thumbnailSizes[size]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的缩略图尺寸，可选 small、medium、large"})
+		return
+	}
+	record := loadFileByID(c)
+	if record == nil {
+		return
+	}
+	if record.Blob == "" || !isPreviewable(record.ContentType) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该文件没有缩略图"})
+		return
+	}
+
+	preview, err := previewRepo.Get(record.Blob)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询缩略图失败"})
+		return
+	}
+	if preview == nil {
+		// 后台任务还没来得及登记
+		enqueuePreview(record)
+		preview = &models.Preview{Status: models.PreviewPending}
+	}
+	switch preview.Status {
+	case models.PreviewPending:
+		c.Header("Retry-After", "2")
+		c.JSON(http.StatusAccepted, gin.H{"success": true, "message": "缩略图生成中"})
+		return
+	case models.PreviewFailed:
+		c.JSON(http.StatusNotFound, gin.H{"error": "无法为该文件生成缩略图"})
+		return
+	}
+
+	serveThumbnail(c, record, preview, size)
+}
+
+// 发送已生成的缩略图
+func serveThumbnail(c *gin.Context, record *models.File, preview *models.Preview, size string) {
+	name := strings.TrimSuffix(record.Name, path.Ext(record.Name)) + "-" + size + "." + strings.Replace(preview.Format, "jpeg", "jpg", 1)
+	// 重新生成（如补读到照片方向）后 ETag 随之变化
+	etag := fmt.Sprintf(`"%s-%s-%d"`, record.Blob, size, preview.UpdatedAt.Unix())
+	serveObject(c, thumbnailPath(record.Blob, size, preview.Format), etag,
+		name, "image/"+preview.Format, record.UpdatedAt, false)
+}
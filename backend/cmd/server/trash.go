@@ -0,0 +1,338 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 回收站保留时长，超过后由后台任务彻底删除，可通过 FAMILYDRIVE_TRASH_RETENTION 配置（如 "168h"）
+var trashRetention = 30 * 24 * time.Hour
+
+// 回收站条目信息结构体
+type TrashInfo struct {
+	ID        int64  `json:"id"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	SpaceID   int64  `json:"spaceId"`
+	FolderID  int64  `json:"folderId"`
+	DeletedAt string `json:"deletedAt"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+func toTrashInfo(t *models.TrashItem) TrashInfo {
+	return TrashInfo{
+		ID:        t.ID,
+		Kind:      t.Kind,
+		Name:      t.Name,
+		Size:      t.Size,
+		SpaceID:   t.SpaceID,
+		FolderID:  t.FolderID,
+		DeletedAt: t.DeletedAt.Format(time.RFC3339),
+		ExpiresAt: t.DeletedAt.Add(trashRetention).Format(time.RFC3339),
+	}
+}
+
+// 读取回收站相关配置
+func initTrash() {
+	if v := os.Getenv("FAMILYDRIVE_TRASH_RETENTION"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			trashRetention = d
+		}
+	}
+}
+
+// 回收站中文件的逻辑路径，按文件 ID 区分，避免与新上传的同名文件冲突
+func trashStoragePath(fileID int64) string {
+	return path.Join("trash", strconv.FormatInt(fileID, 10))
+}
+
+// 在目标目录下找一个未被占用的名称：重名时追加 " (1)"、" (2)"……
+// keepExt 为 true 时编号加在扩展名之前
+func uniqueName(spaceID, parentID int64, name string, keepExt bool) (string, error) {
+	base, ext := name, ""
+	if keepExt {
+		ext = filepath.Ext(name)
+		base = strings.TrimSuffix(name, ext)
+	}
+	candidate := name
+	for i := 1; ; i++ {
+		taken, err := nameTaken(spaceID, parentID, candidate)
+		if err != nil || !taken {
+			return candidate, err
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+}
+
+func moveFileToTrash(record *models.File, trashID int64) error {
+	record.Path = trashStoragePath(record.ID)
+	record.TrashID = trashID
+	return fileRepo.Update(record)
+}
+
+// 把文件移入 userID 的回收站
+func trashFile(userID int64, record *models.File) (*models.TrashItem, error) {
+	item := &models.TrashItem{
+		OwnerID:  userID,
+		SpaceID:  record.SpaceID,
+		FolderID: record.FolderID,
+		Kind:     models.TrashFile,
+		ItemID:   record.ID,
+		Name:     record.Name,
+		Size:     record.Size,
+	}
+	if err := trashRepo.Create(item); err != nil {
+		return nil, err
+	}
+	return item, moveFileToTrash(record, item.ID)
+}
+
+// 把目录连同整棵子树（tree 与其中的 files）移入 userID 的回收站
+func trashFolder(userID int64, folder *models.Folder, tree []models.Folder, files []models.File) (*models.TrashItem, error) {
+	item := &models.TrashItem{
+		OwnerID:  userID,
+		SpaceID:  folder.SpaceID,
+		FolderID: folder.ParentID,
+		Kind:     models.TrashFolder,
+		ItemID:   folder.ID,
+		Name:     folder.Name,
+	}
+	for i := range files {
+		item.Size += files[i].Size
+	}
+	if err := trashRepo.Create(item); err != nil {
+		return nil, err
+	}
+
+	for i := range files {
+		if err := moveFileToTrash(&files[i], item.ID); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range tree {
+		if err := folderRepo.SetTrash(f.ID, item.ID); err != nil {
+			return nil, err
+		}
+	}
+	return item, nil
+}
+
+// 恢复回收站条目：回到原目录，原目录已不存在时回到空间根目录，重名时自动改名。
+// 分享记录不受删除影响，已撤销的分享恢复后仍保持撤销
+func restoreTrash(item *models.TrashItem) (parentID int64, name string, err error) {
+	if item.FolderID != 0 {
+		parent, err := folderRepo.GetByID(item.FolderID)
+		if err != nil {
+			return 0, "", err
+		}
+		if parent != nil && parent.SpaceID == item.SpaceID {
+			parentID = parent.ID
+		}
+	}
+
+	folders, err := folderRepo.ListByTrash(item.ID)
+	if err != nil {
+		return 0, "", err
+	}
+	files, err := fileRepo.ListByTrash(item.ID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	name = item.Name
+	if item.Kind == models.TrashFolder {
+		if name, err = uniqueName(item.SpaceID, parentID, item.Name, false); err != nil {
+			return 0, "", err
+		}
+		for _, f := range folders {
+			if f.ID == item.ItemID {
+				if err := folderRepo.Move(f.ID, parentID); err != nil {
+					return 0, "", err
+				}
+				if err := folderRepo.Rename(f.ID, name); err != nil {
+					return 0, "", err
+				}
+			}
+			if err := folderRepo.SetTrash(f.ID, 0); err != nil {
+				return 0, "", err
+			}
+		}
+	}
+
+	for i := range files {
+		record := &files[i]
+		if record.ID == item.ItemID && item.Kind == models.TrashFile {
+			record.FolderID = parentID
+		}
+		if record.Name, err = uniqueName(record.SpaceID, record.FolderID, record.Name, true); err != nil {
+			return 0, "", err
+		}
+		if item.Kind == models.TrashFile {
+			name = record.Name
+		}
+		record.Path = storagePath(record.SpaceID, record.FolderID, record.Name)
+		record.TrashID = 0
+		if err := fileRepo.Update(record); err != nil {
+			return 0, "", err
+		}
+	}
+
+	return parentID, name, trashRepo.Delete(item.ID)
+}
+
+// 彻底删除回收站条目及其中的文件和目录
+func purgeTrash(item *models.TrashItem) error {
+	files, err := fileRepo.ListByTrash(item.ID)
+	if err != nil {
+		return err
+	}
+	for i := range files {
+		if err := removeFile(&files[i]); err != nil {
+			return err
+		}
+	}
+
+	folders, err := folderRepo.ListByTrash(item.ID)
+	if err != nil {
+		return err
+	}
+	for _, f := range folders {
+		if err := folderRepo.Delete(f.ID); err != nil {
+			return err
+		}
+		if err := shareRepo.DeleteByFolder(f.ID); err != nil {
+			return err
+		}
+	}
+	return trashRepo.Delete(item.ID)
+}
+
+// 加载路径参数 :id 指定的回收站条目，只能操作自己的回收站，失败时已写好响应
+func loadTrashItem(c *gin.Context) *models.TrashItem {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的条目ID"})
+		return nil
+	}
+	item, err := trashRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询回收站失败"})
+		return nil
+	}
+	if item == nil || item.OwnerID != int64(c.GetInt("userID")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "条目不存在"})
+		return nil
+	}
+	return item
+}
+
+// 列出当前用户的回收站
+func listTrash(c *gin.Context) {
+	items, err := trashRepo.ListByOwner(int64(c.GetInt("userID")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询回收站失败"})
+		return
+	}
+
+	list := make([]TrashInfo, 0, len(items))
+	for i := range items {
+		list = append(list, toTrashInfo(&items[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// 从回收站恢复
+func restoreTrashItem(c *gin.Context) {
+	item := loadTrashItem(c)
+	if item == nil || !requireSpaceAccess(c, item.SpaceID) {
+		return
+	}
+
+	folderID, name, err := restoreTrash(item)
+	if err != nil {
+		fmt.Printf("⚠️  恢复回收站条目失败 %d: %v\n", item.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"kind":     item.Kind,
+			"name":     name,
+			"spaceId":  item.SpaceID,
+			"folderId": folderID,
+		},
+		"message": "已恢复",
+	})
+}
+
+// 彻底删除单个回收站条目
+func deleteTrashItem(c *gin.Context) {
+	item := loadTrashItem(c)
+	if item == nil {
+		return
+	}
+	if err := purgeTrash(item); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "已彻底删除"})
+}
+
+// 清空当前用户的回收站
+func emptyTrash(c *gin.Context) {
+	items, err := trashRepo.ListByOwner(int64(c.GetInt("userID")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询回收站失败"})
+		return
+	}
+	for i := range items {
+		if err := purgeTrash(&items[i]); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "清空回收站失败"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("回收站已清空，共删除 %d 项", len(items)),
+	})
+}
+
+// 彻底删除超过保留时长的条目
+func purgeExpiredTrash() {
+	items, err := trashRepo.ListBefore(time.Now().Add(-trashRetention))
+	if err != nil {
+		fmt.Printf("⚠️  查询过期回收站条目失败: %v\n", err)
+		return
+	}
+	for i := range items {
+		if err := purgeTrash(&items[i]); err != nil {
+			fmt.Printf("⚠️  清理回收站条目失败 %d: %v\n", items[i].ID, err)
+		}
+	}
+	if len(items) > 0 {
+		fmt.Printf("🧹 已清理 %d 个过期的回收站条目\n", len(items))
+	}
+}
+
+// 后台定期清理回收站
+func startTrashPurger(interval time.Duration) {
+	go func() {
+		for {
+			purgeExpiredTrash()
+			time.Sleep(interval)
+		}
+	}()
+}
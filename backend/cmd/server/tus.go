@@ -0,0 +1,508 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// tus 1.0 断点续传：https://tus.io/protocols/resumable-upload
+const (
+	tusVersion    = "1.0.0"
+	tusBasePath   = "/api/files/tus/"
+	tusExtensions = "creation,termination,checksum,expiration"
+	tusAlgorithms = "sha1,sha256,md5"
+
+	// 协议规定的校验失败状态码
+	statusChecksumMismatch = 460
+)
+
+var (
+	// 未完成上传的存放目录（以 . 开头，启动对齐时会被跳过）
+	tusDir = filepath.Join(uploadDir, ".tus")
+
+	// 超过该时长没有新数据的上传会被回收，可通过 FAMILYDRIVE_TUS_EXPIRY 配置
+	tusExpiry = 24 * time.Hour
+
+	// 单个上传的大小上限，0 表示不限，可通过 FAMILYDRIVE_TUS_MAX_SIZE 配置（字节）
+	tusMaxSize int64
+
+	// 同一上传同一时间只允许一个 PATCH
+	tusLocks   = make(map[string]bool)
+	tusLocksMu sync.Mutex
+)
+
+// 读取 tus 相关配置
+func initTus() {
+	tusDir = filepath.Join(uploadDir, ".tus")
+	if err := os.MkdirAll(tusDir, 0755); err != nil {
+		panic("创建断点续传目录失败: " + err.Error())
+	}
+	if v := os.Getenv("FAMILYDRIVE_TUS_EXPIRY"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			tusExpiry = d
+		}
+	}
+	if v := os.Getenv("FAMILYDRIVE_TUS_MAX_SIZE"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			tusMaxSize = n
+		}
+	}
+}
+
+// 锁定上传，已被其他请求锁定时返回 false。加锁和解锁都在 tusLocksMu 下进行，
+// 表中有记录即表示已锁定
+func tusLock(id string) (unlock func(), ok bool) {
+	tusLocksMu.Lock()
+	defer tusLocksMu.Unlock()
+	if tusLocks[id] {
+		return nil, false
+	}
+	tusLocks[id] = true
+	return func() {
+		tusLocksMu.Lock()
+		delete(tusLocks, id)
+		tusLocksMu.Unlock()
+	}, true
+}
+
+func tusPartPath(id string) string {
+	return filepath.Join(tusDir, id)
+}
+
+func tusExpiresAt(u *models.TusUpload) string {
+	return u.UpdatedAt.Add(tusExpiry).UTC().Format(http.TimeFormat)
+}
+
+// 写入协议发现所需的响应头（OPTIONS）
+func tusDiscoveryHeaders(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Checksum-Algorithm", tusAlgorithms)
+	if tusMaxSize > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(tusMaxSize, 10))
+	}
+}
+
+// TusMiddleware 检查 Tus-Resumable 版本并为所有响应加上协议头
+func TusMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		c.Header("Cache-Control", "no-store")
+		if c.GetHeader("Tus-Resumable") != tusVersion {
+			c.Header("Tus-Version", tusVersion)
+			c.AbortWithStatus(http.StatusPreconditionFailed)
+			return
+		}
+		c.Next()
+	}
+}
+
+// 解析 Upload-Metadata：逗号分隔的 "key base64(value)"，value 可省略
+func parseTusMetadata(header string) (map[string]string, error) {
+	meta := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return meta, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		switch len(parts) {
+		case 1:
+			meta[parts[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("无效的元数据: %s", parts[0])
+			}
+			meta[parts[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("无效的元数据格式")
+		}
+	}
+	return meta, nil
+}
+
+// 加载路径参数 :id 指定的、属于当前用户的上传，失败时已写好响应
+func loadTusUpload(c *gin.Context) *models.TusUpload {
+	upload, err := tusRepo.Get(c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return nil
+	}
+	if upload == nil || upload.OwnerID != int64(c.GetInt("userID")) {
+		c.Status(http.StatusNotFound)
+		return nil
+	}
+	return upload
+}
+
+// 创建上传（creation 扩展）
+func tusCreate(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少或无效的 Upload-Length"})
+		return
+	}
+	if tusMaxSize > 0 && length > tusMaxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "文件超过大小上限"})
+		return
+	}
+	// 创建时按 Upload-Length 检查配额并预占空间
+	if !checkQuota(c, int64(c.GetInt("userID")), length) {
+		return
+	}
+
+	rawMeta := c.GetHeader("Upload-Metadata")
+	meta, err := parseTusMetadata(rawMeta)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 目标空间和目录：元数据中的 folder_id，或 space + path
+	q := c.Request.URL.Query()
+	for _, key := range []string{"folder_id", "space", "path"} {
+		if v, ok := meta[key]; ok && v != "" {
+			q.Set(key, v)
+		}
+	}
+	c.Request.URL.RawQuery = q.Encode()
+	spaceID, folderID, ok := folderFromRequest(c, true)
+	if !ok {
+		return
+	}
+
+	filename := meta["filename"]
+	if filename == "" {
+		filename = meta["name"]
+	}
+	filename = filepath.Base(filename)
+	if !checkUploadTarget(c, spaceID, folderID, filename) {
+		return
+	}
+
+	upload := &models.TusUpload{
+		ID:          strings.ReplaceAll(uuid.NewString(), "-", ""),
+		OwnerID:     int64(c.GetInt("userID")),
+		SpaceID:     spaceID,
+		FolderID:    folderID,
+		Filename:    filename,
+		ContentType: meta["filetype"],
+		IsHidden:    meta["is_hidden"] != "false",
+		Length:      length,
+		Metadata:    rawMeta,
+	}
+
+	// 先登记再创建分块文件，后台回收时不会把新建的分块当作残留删除
+	if err := tusRepo.Create(upload); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传失败"})
+		return
+	}
+	part, err := os.Create(tusPartPath(upload.ID))
+	if err != nil {
+		tusRepo.Delete(upload.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建上传失败"})
+		return
+	}
+	part.Close()
+
+	// 空文件直接完成
+	if length == 0 {
+		if _, ok := finishTusUpload(c, upload); !ok {
+			return
+		}
+	}
+
+	fmt.Printf("📦 创建断点续传: %s (%s, %d 字节)\n", upload.ID, filename, length)
+	c.Header("Location", tusBasePath+upload.ID)
+	c.Header("Upload-Expires", tusExpiresAt(upload))
+	c.Status(http.StatusCreated)
+}
+
+// 查询上传进度
+func tusHead(c *gin.Context) {
+	upload := loadTusUpload(c)
+	if upload == nil {
+		return
+	}
+
+	// 数据已全部收到但上次完成失败（目标冲突、存储不可用等），重新尝试完成，
+	// 否则客户端看到 Upload-Offset == Upload-Length 会以为上传已成功
+	if upload.Offset == upload.Length {
+		unlock, ok := tusLock(upload.ID)
+		if !ok {
+			c.Status(http.StatusLocked)
+			return
+		}
+		defer unlock()
+		if upload = loadTusUpload(c); upload == nil {
+			return
+		}
+		record, ok := finishTusUpload(c, upload)
+		if !ok {
+			return
+		}
+		c.Header("Upload-File-Id", strconv.FormatInt(record.ID, 10))
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", tusExpiresAt(upload))
+	if upload.Metadata != "" {
+		c.Header("Upload-Metadata", upload.Metadata)
+	}
+	c.Status(http.StatusOK)
+}
+
+// 解析 Upload-Checksum: "<算法> <base64 摘要>"
+func parseTusChecksum(header string) (hash.Hash, []byte, error) {
+	parts := strings.Fields(header)
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("无效的 Upload-Checksum")
+	}
+	want, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("无效的 Upload-Checksum")
+	}
+	switch parts[0] {
+	case "sha1":
+		return sha1.New(), want, nil
+	case "sha256":
+		return sha256.New(), want, nil
+	case "md5":
+		return md5.New(), want, nil
+	}
+	return nil, nil, fmt.Errorf("不支持的校验算法: %s", parts[0])
+}
+
+// 追加数据
+func tusPatch(c *gin.Context) {
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.Status(http.StatusUnsupportedMediaType)
+		return
+	}
+
+	unlock, ok := tusLock(c.Param("id"))
+	if !ok {
+		c.Status(http.StatusLocked)
+		return
+	}
+	defer unlock()
+
+	upload := loadTusUpload(c)
+	if upload == nil {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	if offset != upload.Offset {
+		c.Status(http.StatusConflict)
+		return
+	}
+
+	var checksum hash.Hash
+	var want []byte
+	if header := c.GetHeader("Upload-Checksum"); header != "" {
+		checksum, want, err = parseTusChecksum(header)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	part, err := os.OpenFile(tusPartPath(upload.ID), os.O_WRONLY, 0644)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	defer part.Close()
+	if _, err := part.Seek(offset, io.SeekStart); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	// 最多读取剩余长度，多出的数据视为错误请求
+	remaining := upload.Length - offset
+	var w io.Writer = part
+	if checksum != nil {
+		w = io.MultiWriter(part, checksum)
+	}
+	written, copyErr := io.Copy(w, io.LimitReader(c.Request.Body, remaining))
+	if copyErr == nil {
+		var probe [1]byte
+		if n, _ := c.Request.Body.Read(probe[:]); n > 0 {
+			part.Truncate(offset)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "数据超过 Upload-Length"})
+			return
+		}
+	}
+
+	// 带校验的分块必须完整且摘要一致，否则整块丢弃
+	if checksum != nil {
+		if copyErr != nil || string(checksum.Sum(nil)) != string(want) {
+			part.Truncate(offset)
+			if copyErr != nil {
+				c.Status(http.StatusBadRequest)
+			} else {
+				c.Status(statusChecksumMismatch)
+			}
+			return
+		}
+	}
+
+	// 连接中断时保留已收到的部分，客户端可从新偏移继续
+	upload.Offset = offset + written
+	if err := tusRepo.UpdateOffset(upload); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if copyErr != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	// 上次完成失败时，客户端以当前偏移发送空的 PATCH 也会走到这里重试
+	if upload.Offset == upload.Length {
+		part.Close()
+		record, ok := finishTusUpload(c, upload)
+		if !ok {
+			return
+		}
+		c.Header("Upload-File-Id", strconv.FormatInt(record.ID, 10))
+	} else {
+		c.Header("Upload-Expires", tusExpiresAt(upload))
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// 终止上传（termination 扩展）
+func tusDelete(c *gin.Context) {
+	unlock, ok := tusLock(c.Param("id"))
+	if !ok {
+		c.Status(http.StatusLocked)
+		return
+	}
+	defer unlock()
+
+	upload := loadTusUpload(c)
+	if upload == nil {
+		return
+	}
+	if err := discardTusUpload(upload); err != nil {
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// 上传完成：移入正式存储位置并登记到文件列表，失败时已写好响应
+func finishTusUpload(c *gin.Context, upload *models.TusUpload) (*models.File, bool) {
+	// 上传期间可能有其他成员创建了同名文件或目录，按普通上传的规则重新检查
+	if !checkUploadTarget(c, upload.SpaceID, upload.FolderID, upload.Filename) {
+		return nil, false
+	}
+
+	partPath := tusPartPath(upload.ID)
+	sum, err := fileChecksum(partPath)
+	if err != nil {
+		fmt.Printf("⚠️  完成断点续传失败 %s: %v\n", upload.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
+		return nil, false
+	}
+	record, err := registerUpload(&models.File{
+		OwnerID:     upload.OwnerID,
+		SpaceID:     upload.SpaceID,
+		FolderID:    upload.FolderID,
+		Name:        upload.Filename,
+		Size:        upload.Length,
+		ContentType: upload.ContentType,
+		Checksum:    sum,
+		IsHidden:    upload.IsHidden,
+	}, partPath)
+	if err != nil {
+		fmt.Printf("⚠️  完成断点续传失败 %s: %v\n", upload.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
+		return nil, false
+	}
+	// 文件已登记、分块已移走，残留的记录留给后台回收
+	if err := tusRepo.Delete(upload.ID); err != nil {
+		fmt.Printf("⚠️  删除断点续传记录失败 %s: %v\n", upload.ID, err)
+	}
+
+	fmt.Printf("✅ 断点续传完成: %s → %s\n", upload.ID, record.Name)
+	return record, true
+}
+
+func discardTusUpload(upload *models.TusUpload) error {
+	if err := os.Remove(tusPartPath(upload.ID)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return tusRepo.Delete(upload.ID)
+}
+
+// 回收长时间无活动的未完成上传
+func purgeStaleTusUploads() {
+	stale, err := tusRepo.ListStale(time.Now().Add(-tusExpiry))
+	if err != nil {
+		fmt.Printf("⚠️  查询过期上传失败: %v\n", err)
+		return
+	}
+	for i := range stale {
+		unlock, ok := tusLock(stale[i].ID)
+		if !ok {
+			continue
+		}
+		if err := discardTusUpload(&stale[i]); err != nil {
+			fmt.Printf("⚠️  回收上传失败 %s: %v\n", stale[i].ID, err)
+		}
+		unlock()
+	}
+
+	// 清理没有对应记录的残留分块
+	entries, err := os.ReadDir(tusDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		upload, err := tusRepo.Get(entry.Name())
+		if err == nil && upload == nil {
+			os.Remove(filepath.Join(tusDir, entry.Name()))
+		}
+	}
+	if len(stale) > 0 {
+		fmt.Printf("🧹 已回收 %d 个过期的断点续传\n", len(stale))
+	}
+}
+
+// 后台定期回收
+func startTusJanitor(interval time.Duration) {
+	go func() {
+		for {
+			purgeStaleTusUploads()
+			time.Sleep(interval)
+		}
+	}()
+}
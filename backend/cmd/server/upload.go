@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 流式接收到的上传内容
+type receivedFile struct {
+	Path     string // 临时文件
+	Size     int64
+	Checksum string // sha256
+}
+
+// 把 r 流式写入临时文件，同时计算 sha256；写入量超过剩余配额时立即中止。
+// 失败时已写好响应并删除临时文件；成功后临时文件由调用方删除（已放入内容存储时不受影响）
+func receiveFile(c *gin.Context, userID int64, r io.Reader) (*receivedFile, bool) {
+	available, err := quotaAvailable(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询配额失败"})
+		return nil, false
+	}
+
+	dst, err := newTempFile()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建文件失败"})
+		return nil, false
+	}
+	defer dst.Close()
+
+	// 多读一个字节，用来判断是否超出配额
+	if available >= 0 {
+		r = io.LimitReader(r, available+1)
+	}
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, hasher), r)
+	if err == nil {
+		err = dst.Close()
+	}
+	if err != nil {
+		os.Remove(dst.Name())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
+		return nil, false
+	}
+	if available >= 0 && size > available {
+		os.Remove(dst.Name())
+		// 按已收到的大小给出 413 或 507
+		if checkQuota(c, userID, size) {
+			c.JSON(http.StatusInsufficientStorage, gin.H{"error": "存储空间不足", "available": available})
+		}
+		return nil, false
+	}
+
+	return &receivedFile{
+		Path:     dst.Name(),
+		Size:     size,
+		Checksum: hex.EncodeToString(hasher.Sum(nil)),
+	}, true
+}
+
+// 解析 Content-Digest 头（RFC 9530）中的 sha-256 摘要，没有 sha-256 时返回 nil
+func parseContentDigest(header string) ([]byte, error) {
+	for _, item := range strings.Split(header, ",") {
+		alg, value, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || !strings.EqualFold(alg, "sha-256") {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), ":")
+		sum, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("无效的 Content-Digest")
+		}
+		return sum, nil
+	}
+	return nil, nil
+}
+
+// 以原始请求体上传单个文件：PUT /api/files/raw/<目录>/<文件名>，
+// 目录相对于 space 指定空间（默认私人空间）的根目录，缺失的目录会自动创建。
+// 可带 Content-Digest: sha-256=:<base64>: 校验内容；新建返回 201，覆盖同名文件返回 200
+func uploadRaw(c *gin.Context) {
+	userID := int64(c.GetInt("userID"))
+
+	if c.Request.ContentLength > 0 && !checkQuota(c, userID, c.Request.ContentLength) {
+		return
+	}
+	digest, err := parseContentDigest(c.GetHeader("Content-Digest"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dir, filename := path.Split(strings.TrimPrefix(c.Param("path"), "/"))
+	if filename == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件名"})
+		return
+	}
+	q := c.Request.URL.Query()
+	q.Del("folder_id")
+	q.Del("parent_id")
+	q.Set("path", dir)
+	c.Request.URL.RawQuery = q.Encode()
+	// 请求体就是文件内容，不能被 FormValue 当作表单解析
+	c.Request.Form = q
+	spaceID, folderID, ok := folderFromRequest(c, true)
+	if !ok {
+		return
+	}
+	if !checkUploadTarget(c, spaceID, folderID, filename) {
+		return
+	}
+	existing, err := fileRepo.GetInFolder(spaceID, folderID, filename)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件记录失败"})
+		return
+	}
+
+	received, ok := receiveFile(c, userID, c.Request.Body)
+	if !ok {
+		return
+	}
+	defer os.Remove(received.Path)
+
+	if digest != nil {
+		if sum, _ := hex.DecodeString(received.Checksum); !bytes.Equal(sum, digest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "校验和不匹配"})
+			return
+		}
+	}
+
+	// 通用的二进制类型（curl --data-binary 默认是表单类型）按内容识别
+	contentType := c.ContentType()
+	switch contentType {
+	case "application/octet-stream", "application/x-www-form-urlencoded":
+		contentType = ""
+	}
+
+	record, err := registerUpload(&models.File{
+		OwnerID:     userID,
+		SpaceID:     spaceID,
+		FolderID:    folderID,
+		Name:        filename,
+		Size:        received.Size,
+		ContentType: contentType,
+		Checksum:    received.Checksum,
+		IsHidden:    c.Query("is_hidden") != "false",
+	}, received.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件记录失败"})
+		return
+	}
+
+	status := http.StatusCreated
+	if existing != nil {
+		status = http.StatusOK
+	}
+	c.JSON(status, gin.H{
+		"success": true,
+		"data":    toFileInfo(record),
+		"message": "文件上传成功",
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"familydrive/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 列出本家庭的账号及其角色（管理员）
+func listUsers(c *gin.Context) {
+	users, err := authService.Members(householdID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户失败"})
+		return
+	}
+	list := make([]gin.H, 0, len(users))
+	for i := range users {
+		list = append(list, userInfo(&users[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"users": list,
+			"roles": auth.Roles,
+		},
+	})
+}
+
+// 修改本家庭账号的角色（管理员），请求体 {"role": "parent"}。该账号需重新登录后以新角色使用
+func setUserRole(c *gin.Context) {
+	id, ok := paramUserID(c)
+	if !ok {
+		return
+	}
+	var request struct {
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效请求"})
+		return
+	}
+
+	user, err := authService.SetRole(int64(c.GetInt("userID")), id, request.Role)
+	switch {
+	case errors.Is(err, auth.ErrInvalidRole), errors.Is(err, auth.ErrOwnRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "修改角色失败"})
+		return
+	case user == nil:
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	fmt.Printf("👑 %s 将 %s (ID: %d) 的角色改为 %s\n", c.GetString("username"), user.Username, user.ID, user.Role)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    userInfo(user),
+		"message": "角色已更新",
+	})
+}
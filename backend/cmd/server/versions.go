@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"familydrive/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 历史版本保留策略，满足任一条件的版本会被保留，两者都为 0 表示全部保留：
+// 最近 versionKeep 个（FAMILYDRIVE_VERSIONS_KEEP），或最近 versionDays 天内的（FAMILYDRIVE_VERSIONS_DAYS）
+var (
+	versionKeep = 10
+	versionDays = 0
+)
+
+// 版本信息结构体
+type VersionInfo struct {
+	ID         int64  `json:"id"`
+	Size       int64  `json:"size"`
+	Type       string `json:"type"`
+	Checksum   string `json:"checksum"`
+	UploaderID int64  `json:"uploaderId"`
+	CreatedAt  string `json:"createdAt"`
+	Current    bool   `json:"current"`
+}
+
+// 当前版本的内容信息以文件记录为准
+func toVersionInfo(v *models.FileVersion, record *models.File) VersionInfo {
+	info := VersionInfo{
+		ID:         v.ID,
+		Size:       v.Size,
+		Type:       v.ContentType,
+		Checksum:   v.Checksum,
+		UploaderID: v.UploaderID,
+		CreatedAt:  v.CreatedAt.Format(time.RFC3339),
+		Current:    v.IsCurrent(),
+	}
+	if info.Current {
+		info.Size = record.Size
+		info.Type = record.ContentType
+		info.Checksum = record.Checksum
+	}
+	return info
+}
+
+// 读取版本保留配置
+func initVersions() {
+	if v := os.Getenv("FAMILYDRIVE_VERSIONS_KEEP"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			versionKeep = n
+		}
+	}
+	if v := os.Getenv("FAMILYDRIVE_VERSIONS_DAYS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			versionDays = n
+		}
+	}
+}
+
+// 把文件的当前内容归档为历史版本：版本接过文件对 blob 的引用
+func archiveCurrentVersion(record *models.File) error {
+	current, err := versionRepo.Current(record.ID)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		current = &models.FileVersion{FileID: record.ID, UploaderID: record.OwnerID, CreatedAt: record.UpdatedAt}
+		if err := versionRepo.Create(current); err != nil {
+			return err
+		}
+	}
+	// 没有内容也就没有可保留的版本
+	if record.Blob == "" {
+		return versionRepo.Delete(current.ID)
+	}
+
+	current.Path = blobPath(record.Blob)
+	current.Size = record.Size
+	current.Checksum = record.Blob
+	current.ContentType = record.ContentType
+	return versionRepo.Archive(current)
+}
+
+// 按保留策略清理文件的历史版本
+func pruneVersions(fileID int64) error {
+	if versionKeep == 0 && versionDays == 0 {
+		return nil
+	}
+	versions, err := versionRepo.ListByFile(fileID)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -versionDays)
+	rank := 0
+	for i := range versions {
+		v := &versions[i]
+		if v.IsCurrent() {
+			continue
+		}
+		keep := (versionKeep > 0 && rank < versionKeep) || (versionDays > 0 && v.CreatedAt.After(cutoff))
+		rank++
+		if keep {
+			continue
+		}
+		if err := releaseBlob(v.Checksum); err != nil {
+			return err
+		}
+		if err := versionRepo.Delete(v.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 删除文件的全部历史版本
+func removeVersions(fileID int64) error {
+	versions, err := versionRepo.ListByFile(fileID)
+	if err != nil {
+		return err
+	}
+	for i := range versions {
+		if versions[i].IsCurrent() {
+			continue
+		}
+		if err := releaseBlob(versions[i].Checksum); err != nil {
+			return err
+		}
+	}
+	return versionRepo.DeleteByFile(fileID)
+}
+
+// 按天数保留时，定期清理过期的历史版本
+func startVersionPruner(interval time.Duration) {
+	go func() {
+		for {
+			if versionDays > 0 {
+				ids, err := versionRepo.FilesWithArchivedBefore(time.Now().AddDate(0, 0, -versionDays))
+				if err != nil {
+					fmt.Printf("⚠️  查询过期版本失败: %v\n", err)
+				}
+				for _, id := range ids {
+					if err := pruneVersions(id); err != nil {
+						fmt.Printf("⚠️  清理文件 %d 的历史版本失败: %v\n", id, err)
+					}
+				}
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// 加载路径参数 :id 指定的文件，当前用户必须能访问其所在空间，失败时已写好响应
+func loadFileByID(c *gin.Context) *models.File {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的文件ID"})
+		return nil
+	}
+	record, err := fileRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询文件记录失败"})
+		return nil
+	}
+	if record == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return nil
+	}
+	if !requireSpaceAccess(c, record.SpaceID) {
+		return nil
+	}
+	return record
+}
+
+// 加载路径参数 :vid 指定、属于 record 的版本，失败时已写好响应
+func loadVersion(c *gin.Context, record *models.File) *models.FileVersion {
+	id, err := strconv.ParseInt(c.Param("vid"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的版本ID"})
+		return nil
+	}
+	v, err := versionRepo.GetByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询版本失败"})
+		return nil
+	}
+	if v == nil || v.FileID != record.ID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "版本不存在"})
+		return nil
+	}
+	return v
+}
+
+// 列出文件的版本历史
+func listVersions(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil {
+		return
+	}
+	versions, err := versionRepo.ListByFile(record.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询版本失败"})
+		return
+	}
+
+	list := make([]VersionInfo, 0, len(versions))
+	for i := range versions {
+		list = append(list, toVersionInfo(&versions[i], record))
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// 下载指定版本
+func downloadVersion(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil {
+		return
+	}
+	v := loadVersion(c, record)
+	if v == nil {
+		return
+	}
+
+	if v.IsCurrent() {
+		serveBlob(c, record.Blob, record.Name, record.ContentType, record.UpdatedAt, true)
+	} else {
+		serveBlob(c, v.Checksum, record.Name, v.ContentType, v.CreatedAt, true)
+	}
+}
+
+// 把指定版本恢复为当前版本：作为一次新的上传引用同一份内容，原有版本历史保持不变
+func restoreVersion(c *gin.Context) {
+	record := loadFileByID(c)
+	if record == nil || !requireOwner(c, record.OwnerID) {
+		return
+	}
+	v := loadVersion(c, record)
+	if v == nil {
+		return
+	}
+	if v.IsCurrent() {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": toFileInfo(record), "message": "已是当前版本"})
+		return
+	}
+
+	userID := int64(c.GetInt("userID"))
+	if !checkQuota(c, userID, v.Size) {
+		return
+	}
+	if err := acquireBlob(v.Checksum, v.Size); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "版本内容不存在"})
+		return
+	}
+	restored, err := registerContent(&models.File{
+		OwnerID:     userID,
+		SpaceID:     record.SpaceID,
+		FolderID:    record.FolderID,
+		Name:        record.Name,
+		Size:        v.Size,
+		ContentType: v.ContentType,
+		Checksum:    v.Checksum,
+		IsHidden:    record.IsHidden,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "恢复版本失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    toFileInfo(restored),
+		"message": "已恢复到所选版本",
+	})
+}
@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -46,22 +47,47 @@ func init() {
 	fmt.Printf("💾 聊天系统初始化完成，初始消息数: %d\n", len(chatMessages))
 }
 
+type householdKey struct{}
+
+// WithHousehold 把当前用户所属的家庭放入请求，聊天消息按家庭隔离
+func WithHousehold(r *http.Request, householdID int64) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), householdKey{}, householdID))
+}
+
+// 请求所属家庭的聊天室
+func roomOf(r *http.Request) string {
+	id, _ := r.Context().Value(householdKey{}).(int64)
+	return fmt.Sprintf("household-%d", id)
+}
+
+// 聊天室能看到的消息：本家庭的消息和 general 中的欢迎消息
+func roomMessages(room string) []models.Message {
+	messages := []models.Message{}
+	for _, msg := range chatMessages {
+		if msg.Room == room || msg.Room == "general" {
+			messages = append(messages, msg)
+		}
+	}
+	return messages
+}
+
 func HandleGetMessages(w http.ResponseWriter, r *http.Request) {
 	chatMutex.RLock()
 	defer chatMutex.RUnlock()
+	messages := roomMessages(roomOf(r))
 	
 	// 添加调试日志
 	fmt.Printf("📨 [%s] 处理消息请求，返回 %d 条消息\n", 
-		time.Now().Format("15:04:05"), len(chatMessages))
+		time.Now().Format("15:04:05"), len(messages))
 	
-	for i, msg := range chatMessages {
+	for i, msg := range messages {
 		fmt.Printf("   %d. [%s] %s: %s\n", 
 			i+1, msg.CreatedAt.Format("15:04:05"), msg.Username, msg.Content)
 	}
 	
 	// 转换为前端期望的格式
-	formattedMessages := make([]map[string]interface{}, len(chatMessages))
-	for i, msg := range chatMessages {
+	formattedMessages := make([]map[string]interface{}, len(messages))
+	for i, msg := range messages {
 		formattedMessages[i] = map[string]interface{}{
 			"id":        msg.ID,
 			"user_id":   msg.UserID,
@@ -104,7 +130,7 @@ func HandleChatSend(hub *websocket.Hub) http.HandlerFunc {
 			Username:  request.Username,
 			Content:   request.Content,
 			Type:      "text",
-			Room:      "general",
+			Room:      roomOf(r),
 			CreatedAt: time.Now(),
 		}
 		messageCounter++
@@ -131,7 +157,7 @@ func HandleChatSend(hub *websocket.Hub) http.HandlerFunc {
 		messageBytes, _ := json.Marshal(messageData)
 		
 		fmt.Printf("📢 准备广播消息到 WebSocket\n")
-		hub.Broadcast(messageBytes)
+		hub.BroadcastTo(newMessage.Room, messageBytes)
 		
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -169,17 +195,18 @@ func HandleClearMessages(w http.ResponseWriter, r *http.Request) {
 	chatMutex.Lock()
 	defer chatMutex.Unlock()
 	
-	// 保留系统消息
-	systemMessages := []models.Message{}
+	// 只清空本家庭的消息，保留系统消息
+	room := roomOf(r)
+	remaining := []models.Message{}
 	for _, msg := range chatMessages {
-		if msg.Username == "系统消息" || msg.Username == "家庭助手" {
-			systemMessages = append(systemMessages, msg)
+		if msg.Room != room || msg.Username == "系统消息" || msg.Username == "家庭助手" {
+			remaining = append(remaining, msg)
 		}
 	}
-	chatMessages = systemMessages
+	chatMessages = remaining
 	
-	fmt.Printf("🗑️ [%s] 清空聊天消息，保留 %d 条系统消息\n", 
-		time.Now().Format("15:04:05"), len(chatMessages))
+	fmt.Printf("🗑️ [%s] 清空聊天室 %s 的消息\n", 
+		time.Now().Format("15:04:05"), room)
 	
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -192,6 +219,6 @@ func HandleClearMessages(w http.ResponseWriter, r *http.Request) {
 func HandleWebSocket(hub *websocket.Hub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		fmt.Printf("🔌 [%s] WebSocket 连接请求\n", time.Now().Format("15:04:05"))
-		websocket.ServeWebSocket(hub, w, r)
+		websocket.ServeWebSocket(hub, roomOf(r), w, r)
 	}
 }
\ No newline at end of file
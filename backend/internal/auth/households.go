@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"familydrive/internal/models"
+)
+
+// 默认家庭的名称，引入家庭之前的账号和文件都归入默认家庭
+const defaultHouseholdName = "我的家庭"
+
+// EnsureDefaultHousehold 返回默认家庭（最早创建的家庭），没有家庭时创建，
+// 并把还不属于任何家庭的账号归入默认家庭
+func (s *Service) EnsureDefaultHousehold() (*models.Household, error) {
+	h, err := s.households.First()
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		h = &models.Household{Name: defaultHouseholdName}
+		if err := s.households.Create(h); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := s.users.AssignOrphans(h.ID); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Household 按 ID 查询家庭，不存在时返回 nil, nil
+func (s *Service) Household(id int64) (*models.Household, error) {
+	return s.households.GetByID(id)
+}
+
+// Members 列出家庭成员
+func (s *Service) Members(householdID int64) ([]models.User, error) {
+	return s.users.ListByHousehold(householdID)
+}
+
+// 家庭名称去掉首尾空白，不能为空且不超过 32 个字
+func householdName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > 32 {
+		return "", ErrInvalidHouseholdName
+	}
+	return name, nil
+}
+
+// RenameHousehold 修改家庭名称
+func (s *Service) RenameHousehold(id int64, name string) (*models.Household, error) {
+	name, err := householdName(name)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.households.Rename(id, name); err != nil {
+		return nil, err
+	}
+	return s.households.GetByID(id)
+}
+
+// CreateHousehold 由 actorID 创建新家庭，同时返回一个一次性的管理员邀请及其邀请码，
+// 新家庭的第一位成员凭它注册并管理这个家庭
+func (s *Service) CreateHousehold(actorID int64, name string) (*models.Household, *models.Invitation, string, error) {
+	name, err := householdName(name)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	h := &models.Household{Name: name, CreatedBy: actorID}
+	if err := s.households.Create(h); err != nil {
+		return nil, nil, "", err
+	}
+	invitation, code, err := s.CreateInvitation(h.ID, actorID, RoleAdmin, 1, DefaultInvitationTTL)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	return h, invitation, code, nil
+}
+
+// CreateInvitation 为家庭创建邀请，maxUses 为 1 时一次性有效，为 0 时在 ttl 内不限次数。
+// 邀请码原文只在这里返回
+func (s *Service) CreateInvitation(householdID, actorID int64, role string, maxUses int, ttl time.Duration) (*models.Invitation, string, error) {
+	if !ValidRole(role) {
+		return nil, "", ErrInvalidRole
+	}
+	if maxUses < 0 {
+		maxUses = 1
+	}
+	if ttl <= 0 {
+		ttl = DefaultInvitationTTL
+	}
+	code, err := invitationCode()
+	if err != nil {
+		return nil, "", err
+	}
+	invitation := &models.Invitation{
+		Code:        hashToken(code),
+		HouseholdID: householdID,
+		Role:        role,
+		CreatedBy:   actorID,
+		ExpiresAt:   time.Now().Add(ttl),
+		MaxUses:     maxUses,
+	}
+	if err := s.invitations.Create(invitation); err != nil {
+		return nil, "", err
+	}
+	return invitation, code, nil
+}
+
+// Invitations 列出家庭的全部邀请
+func (s *Service) Invitations(householdID int64) ([]models.Invitation, error) {
+	return s.invitations.ListByHousehold(householdID)
+}
+
+// RevokeInvitation 作废家庭的一个邀请，返回 false 表示没有这个有效邀请
+func (s *Service) RevokeInvitation(householdID, id int64) (bool, error) {
+	return s.invitations.Revoke(householdID, id, time.Now())
+}
+
+// Invitation 查询仍可使用的邀请及其家庭，邀请无效时返回 ErrInvalidInvitation
+func (s *Service) Invitation(code string) (*models.Invitation, *models.Household, error) {
+	i, err := s.invitations.GetByCode(hashToken(strings.TrimSpace(code)))
+	if err != nil {
+		return nil, nil, err
+	}
+	if i == nil || !i.Usable(time.Now()) {
+		return nil, nil, ErrInvalidInvitation
+	}
+	h, err := s.households.GetByID(i.HouseholdID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h == nil {
+		return nil, nil, ErrInvalidInvitation
+	}
+	return i, h, nil
+}
+
+// 邀请码：10 字节随机数的十六进制，便于复制和手动输入
+func invitationCode() (string, error) {
+	buf := make([]byte, 10)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -1,9 +1,13 @@
 package auth
 
 import (
+	"errors"
 	"os"
+	"strconv"
 	"time"
 
+	"familydrive/internal/models"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -17,75 +21,52 @@ func init() {
 	jwtSecret = []byte(secret)
 }
 
-// UserClaims 包含完整的用户信息
+const issuer = "family-drive"
+
+// UserClaims 访问令牌的唯一格式：sub 与 user_id 都是用户 ID，另带用户名、邮箱、角色、所属家庭和会话
 type UserClaims struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID      int    `json:"user_id"`
+	Username    string `json:"username"`
+	Email       string `json:"email"`
+	Role        string `json:"role"`
+	HouseholdID int64  `json:"hid"`
+	SessionID   string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-// GenerateAccessToken generates JWT access token valid for duration d.
-func GenerateAccessToken(userID int64, d time.Duration) (string, error) {
-	claims := jwt.MapClaims{
-		"sub": userID,
-		"exp": time.Now().Add(d).Unix(),
-		"iat": time.Now().Unix(),
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
-}
-
-// ParseAccessToken validates token and returns subject (user id).
-func ParseAccessToken(tok string) (int64, error) {
-	token, err := jwt.Parse(tok, func(t *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-	if err != nil || !token.Valid {
-		return 0, err
-	}
-	if m, ok := token.Claims.(jwt.MapClaims); ok {
-		if subf, ok := m["sub"].(float64); ok {
-			return int64(subf), nil
-		}
-	}
-	return 0, nil
-}
-
-// GenerateUserToken 生成包含完整用户信息的 JWT Token
-func GenerateUserToken(userID int, username, email string, d time.Duration) (string, error) {
+// GenerateToken 为用户 u 的会话 sessionID 签发有效期为 d 的访问令牌
+func GenerateToken(u *models.User, sessionID string, d time.Duration) (string, error) {
+	now := time.Now()
 	claims := &UserClaims{
-		UserID:   userID,
-		Username: username,
-		Email:    email,
+		UserID:      int(u.ID),
+		Username:    u.Username,
+		Email:       u.Email,
+		Role:        u.Role,
+		HouseholdID: u.HouseholdID,
+		SessionID:   sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(d)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Issuer:    "family-drive",
+			Subject:   strconv.FormatInt(u.ID, 10),
+			ExpiresAt: jwt.NewNumericDate(now.Add(d)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Issuer:    issuer,
 		},
 	}
-	
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(jwtSecret)
 }
 
-// ParseUserToken 解析Token并返回完整的用户信息
-func ParseUserToken(tok string) (*UserClaims, error) {
+// ParseToken 校验访问令牌并返回其中的用户信息
+func ParseToken(tok string) (*UserClaims, error) {
 	claims := &UserClaims{}
-	
 	token, err := jwt.ParseWithClaims(tok, claims, func(t *jwt.Token) (interface{}, error) {
 		return jwtSecret, nil
-	})
-	
-	if err != nil || !token.Valid {
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithIssuer(issuer))
+	if err != nil {
 		return nil, err
 	}
-	
+	// 不接受没有过期时间、角色或家庭的令牌，旧令牌需刷新后再用
+	if !token.Valid || claims.ExpiresAt == nil || claims.UserID <= 0 || !ValidRole(claims.Role) || claims.HouseholdID <= 0 {
+		return nil, errors.New("无效的令牌")
+	}
 	return claims, nil
 }
-
-// ValidateToken 验证Token有效性
-func ValidateToken(tok string) bool {
-	_, err := ParseUserToken(tok)
-	return err == nil
-}
\ No newline at end of file
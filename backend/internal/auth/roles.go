@@ -0,0 +1,51 @@
+package auth
+
+// 家庭成员的角色
+const (
+	RoleAdmin  = "admin"  // 管理员：管理账号、配额和存储，同时拥有家长的全部权限
+	RoleParent = "parent" // 家长：可以管理孩子的文件、清空聊天
+	RoleMember = "member" // 普通成员，新注册账号的默认角色
+	RoleChild  = "child"  // 孩子：不能创建公开分享链接
+	RoleGuest  = "guest"  // 访客：只读
+)
+
+// Roles 全部角色，按权限从高到低
+var Roles = []string{RoleAdmin, RoleParent, RoleMember, RoleChild, RoleGuest}
+
+// Permission 接口层检查的一项权限
+type Permission string
+
+const (
+	PermRead           Permission = "read"            // 浏览、下载、预览
+	PermAccount        Permission = "account"         // 管理自己的登录会话
+	PermWrite          Permission = "write"           // 上传、修改、删除自己的内容，发送聊天消息
+	PermShare          Permission = "share"           // 创建和修改公开分享链接
+	PermClearChat      Permission = "chat.clear"      // 清空家庭聊天
+	PermManageChildren Permission = "children.manage" // 管理孩子的文件
+	PermAdmin          Permission = "admin"           // 管理用户、配额和存储
+)
+
+// 权限矩阵：每个角色拥有的权限
+var rolePermissions = map[string][]Permission{
+	RoleAdmin:  {PermRead, PermAccount, PermWrite, PermShare, PermClearChat, PermManageChildren, PermAdmin},
+	RoleParent: {PermRead, PermAccount, PermWrite, PermShare, PermClearChat, PermManageChildren},
+	RoleMember: {PermRead, PermAccount, PermWrite, PermShare},
+	RoleChild:  {PermRead, PermAccount, PermWrite},
+	RoleGuest:  {PermRead, PermAccount},
+}
+
+// ValidRole 是否为已知角色
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can 角色是否拥有权限，未知角色没有任何权限
+func Can(role string, p Permission) bool {
+	for _, granted := range rolePermissions[role] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,497 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"net/mail"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"familydrive/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 注册、登录和刷新的业务错误，消息可直接返回给客户端
+var (
+	ErrMissingFields        = errors.New("用户名、邮箱和密码不能为空")
+	ErrInvalidUsername      = errors.New("用户名不能超过 32 个字")
+	ErrInvalidEmail         = errors.New("邮箱格式不正确")
+	ErrWeakPassword         = errors.New("密码至少需要6位")
+	ErrEmailTaken           = errors.New("邮箱已被注册")
+	ErrUsernameTaken        = errors.New("用户名已被使用")
+	ErrInvalidCredentials   = errors.New("邮箱或密码错误")
+	ErrInvalidRefreshToken  = errors.New("刷新令牌无效或已过期")
+	ErrRefreshTokenReused   = errors.New("刷新令牌已被使用过，请重新登录")
+	ErrSessionRevoked       = errors.New("会话已失效，请重新登录")
+	ErrInvalidRole          = errors.New("无效的角色")
+	ErrOwnRole              = errors.New("不能修改自己的角色")
+	ErrRegistrationClosed   = errors.New("注册需要邀请码")
+	ErrInvalidInvitation    = errors.New("邀请码无效或已过期")
+	ErrInvalidHouseholdName = errors.New("家庭名称不能为空且不超过 32 个字")
+)
+
+// 默认有效期：访问令牌短期有效，过期后用刷新令牌换新
+const (
+	DefaultAccessTTL     = 15 * time.Minute
+	DefaultRefreshTTL    = 30 * 24 * time.Hour
+	DefaultInvitationTTL = 7 * 24 * time.Hour
+)
+
+// 会话最近活动时间的记录间隔，避免每个请求都写库
+const touchInterval = time.Minute
+
+// Device 登录设备的信息，记录在会话中
+type Device struct {
+	Name      string // 客户端自报的设备名，为空时按 UserAgent 推断
+	IP        string
+	UserAgent string
+}
+
+// Tokens 登录或刷新后签发给客户端的令牌
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // 访问令牌的有效秒数
+}
+
+// Service 唯一的认证服务：账号保存在 users 表并属于 households 中的一个家庭，
+// 每次登录在 sessions 表中记一个会话，
+// 访问令牌为带会话 ID 的 UserClaims 格式 JWT，刷新令牌保存在 refresh_tokens 表。
+// conn 可以是 SQLite 或 MySQL。
+//
+// 刷新令牌只能使用一次：每次刷新作废旧令牌并签发新令牌。已作废的令牌再次出现，
+// 说明它可能已被他人窃取，此时作废整个会话，双方都需重新登录。
+// 会话被作废（登出、远程下线）后，其访问令牌和刷新令牌立即失效。
+//
+// OpenRegistration 为 false 时只能凭邀请码注册（第一个账号除外）
+type Service struct {
+	users         *models.UserRepository
+	refreshTokens *models.RefreshTokenRepository
+	sessions      *models.SessionRepository
+	households    *models.HouseholdRepository
+	invitations   *models.InvitationRepository
+
+	AccessTTL        time.Duration
+	RefreshTTL       time.Duration
+	OpenRegistration bool
+}
+
+func NewService(conn *sql.DB) *Service {
+	return &Service{
+		users:            models.NewUserRepository(conn),
+		refreshTokens:    models.NewRefreshTokenRepository(conn),
+		sessions:         models.NewSessionRepository(conn),
+		households:       models.NewHouseholdRepository(conn),
+		invitations:      models.NewInvitationRepository(conn),
+		AccessTTL:        DefaultAccessTTL,
+		RefreshTTL:       DefaultRefreshTTL,
+		OpenRegistration: true,
+	}
+}
+
+// 邮箱不区分大小写，统一按小写保存和查找
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Register 创建账号，不自动登录。带邀请码时加入邀请的家庭并使用邀请的角色；
+// 不带邀请码时加入默认家庭，还没有管理员时新账号成为管理员，否则为普通成员
+func (s *Service) Register(username, email, password, inviteCode string) (*models.User, error) {
+	username = strings.TrimSpace(username)
+	email = normalizeEmail(email)
+	inviteCode = strings.TrimSpace(inviteCode)
+	if username == "" || email == "" || password == "" {
+		return nil, ErrMissingFields
+	}
+	if utf8.RuneCountInString(username) > 32 {
+		return nil, ErrInvalidUsername
+	}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+		return nil, ErrInvalidEmail
+	}
+	if len(password) < 6 {
+		return nil, ErrWeakPassword
+	}
+
+	u := &models.User{Username: username, Email: email}
+	var invitation *models.Invitation
+	if inviteCode != "" {
+		i, err := s.invitations.GetByCode(hashToken(inviteCode))
+		if err != nil {
+			return nil, err
+		}
+		if i == nil || !i.Usable(time.Now()) {
+			return nil, ErrInvalidInvitation
+		}
+		invitation = i
+		u.HouseholdID, u.Role = i.HouseholdID, i.Role
+	} else {
+		// 关闭开放注册后仍允许创建第一个账号，否则无人能发出邀请
+		if !s.OpenRegistration {
+			if n, err := s.users.Count(); err != nil {
+				return nil, err
+			} else if n > 0 {
+				return nil, ErrRegistrationClosed
+			}
+		}
+		h, err := s.EnsureDefaultHousehold()
+		if err != nil {
+			return nil, err
+		}
+		u.HouseholdID, u.Role = h.ID, RoleMember
+		if n, err := s.users.CountByRole(RoleAdmin); err != nil {
+			return nil, err
+		} else if n == 0 {
+			u.Role = RoleAdmin
+		}
+	}
+
+	if existing, err := s.users.GetByEmail(email); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, ErrEmailTaken
+	}
+	if existing, err := s.users.GetByUsername(username); err != nil {
+		return nil, err
+	} else if existing != nil {
+		return nil, ErrUsernameTaken
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	u.PasswordHash = string(hash)
+	if invitation == nil {
+		if err := s.users.Create(u); err != nil {
+			return nil, err
+		}
+		return u, nil
+	}
+	// 账号和邀请的使用次数在同一事务中写入，注册失败不会用掉邀请
+	ok, err := s.invitations.Redeem(invitation.ID, u, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidInvitation
+	}
+	return u, nil
+}
+
+// Login 校验邮箱和密码，成功时为设备开一个会话并签发访问令牌和刷新令牌
+func (s *Service) Login(email, password string, device Device) (*models.User, *Tokens, error) {
+	u, err := s.users.GetByEmail(normalizeEmail(email))
+	if err != nil {
+		return nil, nil, err
+	}
+	if u == nil || bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) != nil {
+		return nil, nil, ErrInvalidCredentials
+	}
+	id, err := randomToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	name := truncate(strings.TrimSpace(device.Name), 64)
+	if name == "" {
+		name = deviceName(device.UserAgent)
+	}
+	err = s.sessions.Create(&models.Session{
+		ID:         id,
+		UserID:     u.ID,
+		DeviceName: name,
+		IP:         device.IP,
+		UserAgent:  truncate(device.UserAgent, 512),
+		ExpiresAt:  time.Now().Add(s.RefreshTTL),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, err := s.issue(u, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return u, tokens, nil
+}
+
+// Refresh 用刷新令牌换取一对新令牌，旧的刷新令牌随即作废。
+// 重复使用已作废的令牌时作废整个会话并返回 ErrRefreshTokenReused
+func (s *Service) Refresh(refreshToken, ip string) (*models.User, *Tokens, error) {
+	hash := hashToken(refreshToken)
+	t, err := s.refreshTokens.Get(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if t == nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	now := time.Now()
+	if now.After(t.ExpiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	// 会话已结束（登出、远程下线）时令牌一并失效，不算重复使用
+	session, err := s.sessions.Get(t.Family)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil || !session.Active(now) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	if t.RevokedAt != nil {
+		return nil, nil, s.reused(t.Family, now)
+	}
+	// 条件更新保证并发的两次刷新只有一次成功，另一次按重复使用处理
+	ok, err := s.refreshTokens.Revoke(hash, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, s.reused(t.Family, now)
+	}
+
+	u, err := s.users.GetByID(t.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u == nil {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+	tokens, err := s.issue(u, t.Family)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.sessions.Extend(t.Family, now.Add(s.RefreshTTL)); err != nil {
+		return nil, nil, err
+	}
+	if err := s.sessions.Touch(t.Family, ip, now, touchInterval); err != nil {
+		return nil, nil, err
+	}
+	return u, tokens, nil
+}
+
+// 作废整个会话，返回 ErrRefreshTokenReused
+func (s *Service) reused(sessionID string, now time.Time) error {
+	if err := s.endSession(sessionID, now); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// 作废会话及其全部刷新令牌
+func (s *Service) endSession(sessionID string, now time.Time) error {
+	if _, err := s.refreshTokens.RevokeFamily(sessionID, now); err != nil {
+		return err
+	}
+	_, err := s.sessions.Revoke(sessionID, now)
+	return err
+}
+
+// Logout 结束会话，会话不存在时不报错
+func (s *Service) Logout(sessionID string) error {
+	return s.endSession(sessionID, time.Now())
+}
+
+// Sessions 列出用户仍然有效的会话
+func (s *Service) Sessions(userID int64) ([]models.Session, error) {
+	return s.sessions.ListActive(userID, time.Now())
+}
+
+// RevokeSession 远程结束用户自己的某个会话，返回 false 表示没有这个有效会话
+func (s *Service) RevokeSession(userID int64, sessionID string) (bool, error) {
+	now := time.Now()
+	session, err := s.sessions.Get(sessionID)
+	if err != nil {
+		return false, err
+	}
+	if session == nil || session.UserID != userID || !session.Active(now) {
+		return false, nil
+	}
+	return true, s.endSession(sessionID, now)
+}
+
+// PurgeExpired 删除过期的刷新令牌和会话，返回各自删除的条数
+func (s *Service) PurgeExpired() (tokens, sessions int64, err error) {
+	now := time.Now()
+	if tokens, err = s.refreshTokens.DeleteExpired(now); err != nil {
+		return 0, 0, err
+	}
+	sessions, err = s.sessions.DeleteExpired(now)
+	return tokens, sessions, err
+}
+
+// Authenticate 校验访问令牌及其会话，并记录会话的最近活动
+func (s *Service) Authenticate(accessToken, ip string) (*UserClaims, error) {
+	claims, err := ParseToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	if claims.SessionID == "" {
+		return nil, ErrSessionRevoked
+	}
+	now := time.Now()
+	session, err := s.sessions.Get(claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.UserID != int64(claims.UserID) || !session.Active(now) {
+		return nil, ErrSessionRevoked
+	}
+	if err := s.sessions.Touch(session.ID, ip, now, touchInterval); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// User 按 ID 查询账号，不存在时返回 nil, nil
+func (s *Service) User(id int64) (*models.User, error) {
+	return s.users.GetByID(id)
+}
+
+// SetRole 由 actorID 修改同一家庭中 userID 的角色，用户不存在或不在同一家庭时返回 nil, nil。
+// 令牌中带有角色，修改后结束该用户的全部会话，让新角色立即生效
+func (s *Service) SetRole(actorID, userID int64, role string) (*models.User, error) {
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	// 防止管理员把自己降级后无人能管理账号
+	if actorID == userID {
+		return nil, ErrOwnRole
+	}
+	actor, err := s.users.GetByID(actorID)
+	if err != nil || actor == nil {
+		return nil, err
+	}
+	target, err := s.users.GetByID(userID)
+	if err != nil || target == nil || target.HouseholdID != actor.HouseholdID {
+		return nil, err
+	}
+	ok, err := s.users.SetRole(userID, role)
+	if err != nil || !ok {
+		return nil, err
+	}
+	now := time.Now()
+	sessions, err := s.sessions.ListActive(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		if err := s.endSession(session.ID, now); err != nil {
+			return nil, err
+		}
+	}
+	return s.users.GetByID(userID)
+}
+
+// EnsureAdmins 把 ids 中的账号设为管理员；ids 为空且还没有管理员时，
+// 把最早注册的账号设为管理员（升级前的旧账号都是普通成员）。返回被设为管理员的账号
+func (s *Service) EnsureAdmins(ids []int64) ([]models.User, error) {
+	if len(ids) == 0 {
+		n, err := s.users.CountByRole(RoleAdmin)
+		if err != nil || n > 0 {
+			return nil, err
+		}
+		users, err := s.users.List()
+		if err != nil || len(users) == 0 {
+			return nil, err
+		}
+		ids = []int64{users[0].ID}
+	}
+
+	promoted := []models.User{}
+	for _, id := range ids {
+		u, err := s.users.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if u == nil || u.Role == RoleAdmin {
+			continue
+		}
+		if _, err := s.users.SetRole(id, RoleAdmin); err != nil {
+			return nil, err
+		}
+		u.Role = RoleAdmin
+		promoted = append(promoted, *u)
+	}
+	return promoted, nil
+}
+
+// 为会话签发一对新令牌，刷新令牌的 Family 即会话 ID
+func (s *Service) issue(u *models.User, sessionID string) (*Tokens, error) {
+	access, err := GenerateToken(u, sessionID, s.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	err = s.refreshTokens.Create(&models.RefreshToken{
+		Token:     hashToken(refresh),
+		UserID:    u.ID,
+		Family:    sessionID,
+		ExpiresAt: time.Now().Add(s.RefreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Tokens{AccessToken: access, RefreshToken: refresh, ExpiresIn: int64(s.AccessTTL / time.Second)}, nil
+}
+
+// 32 字节随机数的十六进制
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// 库中只保存刷新令牌的 sha256，泄露的库内容不能直接拿来刷新
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// 按字符截断
+func truncate(v string, n int) string {
+	if utf8.RuneCountInString(v) <= n {
+		return v
+	}
+	return string([]rune(v)[:n])
+}
+
+// 按 UserAgent 推断设备名，如“Chrome · Windows”
+func deviceName(ua string) string {
+	var browser, system string
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"}, {"Safari/", "Safari"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iPhone"}, {"iPad", "iPad"}, {"Android", "Android"},
+		{"Windows", "Windows"}, {"Macintosh", "Mac"}, {"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			system = o.name
+			break
+		}
+	}
+	switch {
+	case browser != "" && system != "":
+		return browser + " · " + system
+	case system != "":
+		return system
+	case browser != "":
+		return browser
+	}
+	return "未知设备"
+}
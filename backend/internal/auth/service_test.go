@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	appdb "familydrive/internal/db"
+	"familydrive/internal/models"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	if err := appdb.Init(filepath.Join(t.TempDir(), "test.db")); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(appdb.Close)
+	return NewService(appdb.DB())
+}
+
+func TestRegisterKeepsInvitationWhenCreateFails(t *testing.T) {
+	s := newTestService(t)
+	h, err := s.EnsureDefaultHousehold()
+	if err != nil {
+		t.Fatal(err)
+	}
+	invitation, code, err := s.CreateInvitation(h.ID, 0, RoleParent, 1, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 通过检查之后插入账号失败
+	if _, err := appdb.DB().Exec(`CREATE TRIGGER fail_insert BEFORE INSERT ON users WHEN NEW.username = 'boom'
+BEGIN SELECT RAISE(ABORT, 'boom'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Register("boom", "boom@example.com", "secret1", code); err == nil {
+		t.Fatal("register boom: want error")
+	}
+	list, err := s.Invitations(h.ID)
+	if err != nil || len(list) != 1 || list[0].Uses != 0 {
+		t.Fatalf("invitation after failed register: %+v, %v", list, err)
+	}
+
+	u, err := s.Register("alice", "alice@example.com", "secret1", code)
+	if err != nil {
+		t.Fatalf("register alice: %v", err)
+	}
+	if u.HouseholdID != h.ID || u.Role != RoleParent {
+		t.Errorf("alice: household %d role %s, want %d %s", u.HouseholdID, u.Role, h.ID, RoleParent)
+	}
+	if _, err := s.Register("bob", "bob@example.com", "secret1", code); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("reuse single-use invitation: err = %v, want ErrInvalidInvitation", err)
+	}
+	if _, _, err := s.Invitation(code); !errors.Is(err, ErrInvalidInvitation) {
+		t.Errorf("invitation %d after use: err = %v", invitation.ID, err)
+	}
+}
+
+// 注册并登录，返回账号和令牌
+func loginAs(t *testing.T, s *Service, username, device string) (*models.User, *Tokens) {
+	t.Helper()
+	email := username + "@example.com"
+	if u, err := s.users.GetByEmail(email); err != nil || u == nil {
+		if _, err := s.Register(username, email, "secret1", ""); err != nil {
+			t.Fatalf("register %s: %v", username, err)
+		}
+	}
+	u, tokens, err := s.Login(email, "secret1", Device{Name: device})
+	if err != nil {
+		t.Fatalf("login %s: %v", username, err)
+	}
+	return u, tokens
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	s := newTestService(t)
+	_, first := loginAs(t, s, "alice", "phone")
+	_, other := loginAs(t, s, "alice", "laptop")
+
+	_, second, err := s.Refresh(first.RefreshToken, "")
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if _, err := s.Authenticate(second.AccessToken, ""); err != nil {
+		t.Fatalf("authenticate refreshed token: %v", err)
+	}
+
+	// 旧刷新令牌再次出现：整个会话作废，包括刚签发的令牌
+	if _, _, err := s.Refresh(first.RefreshToken, ""); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reuse: err = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, _, err := s.Refresh(second.RefreshToken, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after reuse: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	for name, access := range map[string]string{"first": first.AccessToken, "second": second.AccessToken} {
+		if _, err := s.Authenticate(access, ""); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("authenticate %s access token: err = %v, want ErrSessionRevoked", name, err)
+		}
+	}
+
+	// 同一账号的其他会话不受影响
+	if _, err := s.Authenticate(other.AccessToken, ""); err != nil {
+		t.Errorf("other session: %v", err)
+	}
+	if _, _, err := s.Refresh(other.RefreshToken, ""); err != nil {
+		t.Errorf("refresh other session: %v", err)
+	}
+}
+
+func TestRevokedSessionRejectsAccessToken(t *testing.T) {
+	s := newTestService(t)
+	admin, _ := loginAs(t, s, "admin", "desktop")
+	u, phone := loginAs(t, s, "alice", "phone")
+	_, laptop := loginAs(t, s, "alice", "laptop")
+	_, tablet := loginAs(t, s, "alice", "tablet")
+
+	claims, err := s.Authenticate(phone.AccessToken, "")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	// 不能下线别人的会话
+	if ok, err := s.RevokeSession(admin.ID, claims.SessionID); err != nil || ok {
+		t.Errorf("revoke other user's session: %v, %v", ok, err)
+	}
+	if ok, err := s.RevokeSession(u.ID, claims.SessionID); err != nil || !ok {
+		t.Fatalf("revoke session: %v, %v", ok, err)
+	}
+	if _, err := s.Authenticate(phone.AccessToken, ""); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("access token of revoked session: err = %v, want ErrSessionRevoked", err)
+	}
+	if _, _, err := s.Refresh(phone.RefreshToken, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh token of revoked session: err = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// 登出只结束当前会话
+	laptopClaims, err := s.Authenticate(laptop.AccessToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Logout(laptopClaims.SessionID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Authenticate(laptop.AccessToken, ""); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("access token after logout: err = %v, want ErrSessionRevoked", err)
+	}
+	if _, err := s.Authenticate(tablet.AccessToken, ""); err != nil {
+		t.Errorf("untouched session: %v", err)
+	}
+	sessions, err := s.Sessions(u.ID)
+	if err != nil || len(sessions) != 1 || sessions[0].DeviceName != "tablet" {
+		t.Errorf("remaining sessions: %+v, %v", sessions, err)
+	}
+}
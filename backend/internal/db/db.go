@@ -3,6 +3,7 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "modernc.org/sqlite"
 )
@@ -37,18 +38,440 @@ func migrate() error {
 	s := `
 CREATE TABLE IF NOT EXISTS users (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
-    name TEXT NOT NULL,
+    username TEXT NOT NULL,
     email TEXT NOT NULL UNIQUE,
     password_hash TEXT NOT NULL,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+    role TEXT NOT NULL DEFAULT 'member',
+    household_id INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME
 );
 
 CREATE TABLE IF NOT EXISTS refresh_tokens (
     token TEXT PRIMARY KEY,
     user_id INTEGER NOT NULL,
+    family TEXT NOT NULL DEFAULT '',
+    created_at DATETIME,
     expires_at DATETIME NOT NULL,
+    revoked_at DATETIME,
     FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    device_name TEXT NOT NULL DEFAULT '',
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    last_seen_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+
+CREATE TABLE IF NOT EXISTS households (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    created_by INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS invitations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT NOT NULL UNIQUE,
+    household_id INTEGER NOT NULL,
+    role TEXT NOT NULL DEFAULT 'member',
+    created_by INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    max_uses INTEGER NOT NULL DEFAULT 1,
+    uses INTEGER NOT NULL DEFAULT 0,
+    revoked_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_household ON invitations(household_id);
+
+CREATE TABLE IF NOT EXISTS files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    path TEXT NOT NULL UNIQUE,
+    size INTEGER NOT NULL DEFAULT 0,
+    content_type TEXT NOT NULL DEFAULT '',
+    checksum TEXT NOT NULL DEFAULT '',
+    is_hidden INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_files_owner ON files(owner_id);
+
+CREATE TABLE IF NOT EXISTS share_links (
+    token TEXT PRIMARY KEY,
+    file_id INTEGER NOT NULL DEFAULT 0,
+    filename TEXT NOT NULL,
+    password_hash TEXT NOT NULL DEFAULT '',
+    expires_at DATETIME NOT NULL,
+    max_access INTEGER NOT NULL DEFAULT 0,
+    access_count INTEGER NOT NULL DEFAULT 0,
+    created_by INTEGER NOT NULL DEFAULT 0,
+    is_active INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_share_links_file ON share_links(file_id);
+
+CREATE TABLE IF NOT EXISTS folders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INTEGER NOT NULL DEFAULT 0,
+    parent_id INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS spaces (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    kind TEXT NOT NULL,
+    owner_id INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(kind, owner_id)
+);
+
+CREATE TABLE IF NOT EXISTS trash (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INTEGER NOT NULL,
+    space_id INTEGER NOT NULL DEFAULT 0,
+    folder_id INTEGER NOT NULL DEFAULT 0,
+    kind TEXT NOT NULL,
+    item_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    size INTEGER NOT NULL DEFAULT 0,
+    deleted_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_trash_owner ON trash(owner_id);
+
+CREATE TABLE IF NOT EXISTS file_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    file_id INTEGER NOT NULL,
+    path TEXT NOT NULL DEFAULT '',
+    size INTEGER NOT NULL DEFAULT 0,
+    checksum TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT '',
+    uploader_id INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_versions_file ON file_versions(file_id);
+CREATE INDEX IF NOT EXISTS idx_file_versions_uploader ON file_versions(uploader_id);
+
+CREATE TABLE IF NOT EXISTS blobs (
+    hash TEXT PRIMARY KEY,
+    size INTEGER NOT NULL DEFAULT 0,
+    ref_count INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS previews (
+    blob TEXT PRIMARY KEY,
+    status TEXT NOT NULL DEFAULT 'pending',
+    format TEXT NOT NULL DEFAULT '',
+    width INTEGER NOT NULL DEFAULT 0,
+    height INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_previews_status ON previews(status);
+
+CREATE TABLE IF NOT EXISTS content_index (
+    blob TEXT PRIMARY KEY,
+    kind TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_index_status ON content_index(status);
+
+-- 文档全文，trigram 分词支持中文和任意子串匹配
+CREATE VIRTUAL TABLE IF NOT EXISTS content_text USING fts5(blob UNINDEXED, body, tokenize='trigram');
+
+CREATE TABLE IF NOT EXISTS albums (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INTEGER NOT NULL DEFAULT 0,
+    space_id INTEGER NOT NULL DEFAULT 0,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    cover_file_id INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_albums_space ON albums(space_id);
+
+CREATE TABLE IF NOT EXISTS album_items (
+    album_id INTEGER NOT NULL,
+    file_id INTEGER NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    added_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (album_id, file_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_album_items_file ON album_items(file_id);
+
+CREATE TABLE IF NOT EXISTS tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INTEGER NOT NULL,
+    name TEXT NOT NULL COLLATE NOCASE,
+    color TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(owner_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS file_tags (
+    file_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (file_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_tags_tag ON file_tags(tag_id);
+
+CREATE TABLE IF NOT EXISTS favorites (
+    user_id INTEGER NOT NULL,
+    file_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, file_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_favorites_file ON favorites(file_id);
+
+CREATE TABLE IF NOT EXISTS file_metadata (
+    file_id INTEGER NOT NULL,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL DEFAULT '',
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (file_id, key)
+);
+
+CREATE TABLE IF NOT EXISTS quotas (
+    scope TEXT NOT NULL,
+    subject_id INTEGER NOT NULL DEFAULT 0,
+    limit_bytes INTEGER NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(scope, subject_id)
+);
+
+CREATE TABLE IF NOT EXISTS tus_uploads (
+    id TEXT PRIMARY KEY,
+    owner_id INTEGER NOT NULL DEFAULT 0,
+    folder_id INTEGER NOT NULL DEFAULT 0,
+    filename TEXT NOT NULL,
+    content_type TEXT NOT NULL DEFAULT '',
+    is_hidden INTEGER NOT NULL DEFAULT 1,
+    length INTEGER NOT NULL,
+    upload_offset INTEGER NOT NULL DEFAULT 0,
+    metadata TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
 );`
-	_, err := conn.Exec(s)
+	if _, err := conn.Exec(s); err != nil {
+		return err
+	}
+
+	// 旧库的用户名列叫 name，与 MySQL 用户表统一为 username
+	if err := renameColumn("users", "name", "username"); err != nil {
+		return err
+	}
+
+	// 旧库补充新增列
+	columns := []struct{ table, column, def string }{
+		{"files", "folder_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"files", "space_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"folders", "space_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"tus_uploads", "space_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"files", "trash_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"folders", "trash_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"files", "blob", "TEXT NOT NULL DEFAULT ''"},
+		{"share_links", "folder_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"files", "taken_at", "DATETIME"},
+		{"files", "camera", "TEXT NOT NULL DEFAULT ''"},
+		{"files", "orientation", "INTEGER NOT NULL DEFAULT 0"},
+		{"files", "width", "INTEGER NOT NULL DEFAULT 0"},
+		{"files", "height", "INTEGER NOT NULL DEFAULT 0"},
+		{"files", "latitude", "REAL"},
+		{"files", "longitude", "REAL"},
+		{"share_links", "album_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"users", "updated_at", "DATETIME"},
+		{"users", "role", "TEXT NOT NULL DEFAULT 'member'"},
+		{"users", "household_id", "INTEGER NOT NULL DEFAULT 0"},
+		{"refresh_tokens", "family", "TEXT NOT NULL DEFAULT ''"},
+		{"refresh_tokens", "created_at", "DATETIME"},
+		{"refresh_tokens", "revoked_at", "DATETIME"},
+	}
+	for _, col := range columns {
+		if err := addColumn(col.table, col.column, col.def); err != nil {
+			return err
+		}
+	}
+
+	if err := assignSpaces(); err != nil {
+		return err
+	}
+	if err := checkDuplicateUsernames(); err != nil {
+		return err
+	}
+
+	// 依赖新增列的索引：同一空间同一目录下未删除的条目名称唯一
+	_, err := conn.Exec(`
+DROP INDEX IF EXISTS idx_files_folder_name;
+DROP INDEX IF EXISTS idx_folders_parent_name;
+DROP INDEX IF EXISTS idx_files_space_folder_name;
+DROP INDEX IF EXISTS idx_folders_space_parent_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_files_live_name ON files(space_id, folder_id, name) WHERE trash_id = 0;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_folders_live_name ON folders(space_id, parent_id, name) WHERE trash_id = 0;
+CREATE INDEX IF NOT EXISTS idx_files_trash ON files(trash_id) WHERE trash_id <> 0;
+CREATE INDEX IF NOT EXISTS idx_folders_trash ON folders(trash_id) WHERE trash_id <> 0;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users(username);
+CREATE INDEX IF NOT EXISTS idx_users_household ON users(household_id);
+UPDATE refresh_tokens SET family = token WHERE family = '';
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family);`)
+	return err
+}
+
+// assignSpaces 把引入空间之前的数据（space_id = 0）归入空间：
+// 有主的顶层目录和根目录文件进入所有者的私人空间，无主的进入家庭空间
+// （owner_id 为 0，启动时归入默认家庭），子目录和目录中的文件跟随所在目录
+func assignSpaces() error {
+	_, err := conn.Exec(`
+INSERT OR IGNORE INTO spaces(kind, owner_id)
+    SELECT 'family', 0 WHERE EXISTS (SELECT 1 FROM folders WHERE space_id = 0)
+        OR EXISTS (SELECT 1 FROM files WHERE space_id = 0)
+        OR EXISTS (SELECT 1 FROM tus_uploads WHERE space_id = 0);
+
+INSERT OR IGNORE INTO spaces(kind, owner_id)
+    SELECT 'private', owner_id FROM folders WHERE space_id = 0 AND parent_id = 0 AND owner_id > 0
+    UNION SELECT 'private', owner_id FROM files WHERE space_id = 0 AND folder_id = 0 AND owner_id > 0
+    UNION SELECT 'private', owner_id FROM tus_uploads WHERE space_id = 0 AND folder_id = 0 AND owner_id > 0;
+
+UPDATE folders SET space_id = (SELECT id FROM spaces WHERE kind = 'private' AND owner_id = folders.owner_id)
+    WHERE space_id = 0 AND parent_id = 0 AND owner_id > 0;
+UPDATE folders SET space_id = (SELECT id FROM spaces WHERE kind = 'family' AND owner_id = 0)
+    WHERE space_id = 0 AND parent_id = 0;`)
+	if err != nil {
+		return err
+	}
+
+	// 逐层向下传播
+	for {
+		res, err := conn.Exec(`
+UPDATE folders SET space_id = (SELECT p.space_id FROM folders p WHERE p.id = folders.parent_id)
+    WHERE space_id = 0 AND (SELECT p.space_id FROM folders p WHERE p.id = folders.parent_id) > 0`)
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	_, err = conn.Exec(`
+UPDATE folders SET space_id = (SELECT id FROM spaces WHERE kind = 'family' AND owner_id = 0) WHERE space_id = 0;
+
+UPDATE files SET space_id = (SELECT space_id FROM folders WHERE id = files.folder_id)
+    WHERE space_id = 0 AND folder_id <> 0 AND EXISTS (SELECT 1 FROM folders WHERE id = files.folder_id);
+UPDATE files SET space_id = (SELECT id FROM spaces WHERE kind = 'private' AND owner_id = files.owner_id)
+    WHERE space_id = 0 AND folder_id = 0 AND owner_id > 0;
+UPDATE files SET space_id = (SELECT id FROM spaces WHERE kind = 'family' AND owner_id = 0) WHERE space_id = 0;
+
+UPDATE tus_uploads SET space_id = (SELECT space_id FROM folders WHERE id = tus_uploads.folder_id)
+    WHERE space_id = 0 AND folder_id <> 0 AND EXISTS (SELECT 1 FROM folders WHERE id = tus_uploads.folder_id);
+UPDATE tus_uploads SET space_id = (SELECT id FROM spaces WHERE kind = 'private' AND owner_id = tus_uploads.owner_id)
+    WHERE space_id = 0 AND folder_id = 0 AND owner_id > 0;
+UPDATE tus_uploads SET space_id = (SELECT id FROM spaces WHERE kind = 'family' AND owner_id = 0) WHERE space_id = 0;`)
+	return err
+}
+
+// checkDuplicateUsernames 在建立用户名唯一索引前检查旧数据：以前的几套认证各自建过账号，
+// 可能留下同名账号。有重复时返回列出这些用户名和账号 ID 的错误，由管理员改名后再启动
+func checkDuplicateUsernames() error {
+	rows, err := conn.Query(`
+SELECT username, GROUP_CONCAT(id, ', ') FROM users
+GROUP BY username HAVING COUNT(*) > 1 ORDER BY username`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var duplicates []string
+	for rows.Next() {
+		var name, ids string
+		if err := rows.Scan(&name, &ids); err != nil {
+			return err
+		}
+		duplicates = append(duplicates, fmt.Sprintf("%q (ID %s)", name, ids))
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	return fmt.Errorf("users 表中有重复的用户名：%s。请为重复的账号改名后再启动，"+
+		"例如 UPDATE users SET username = '新用户名' WHERE id = 账号ID", strings.Join(duplicates, "、"))
+}
+
+// hasColumn 查询表中是否有某列
+func hasColumn(table, column string) (bool, error) {
+	rows, err := conn.Query("PRAGMA table_info(" + table + ")")
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	exists := false
+	for rows.Next() {
+		var (
+			cid       int
+			name, typ string
+			notNull   int
+			dflt      sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			exists = true
+		}
+	}
+	return exists, rows.Err()
+}
+
+// addColumn 在列不存在时执行 ALTER TABLE ADD COLUMN
+func addColumn(table, column, def string) error {
+	exists, err := hasColumn(table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = conn.Exec("ALTER TABLE " + table + " ADD COLUMN " + column + " " + def)
+	return err
+}
+
+// renameColumn 在旧列存在时把它改名为新列
+func renameColumn(table, from, to string) error {
+	exists, err := hasColumn(table, from)
+	if err != nil || !exists {
+		return err
+	}
+	_, err = conn.Exec("ALTER TABLE " + table + " RENAME COLUMN " + from + " TO " + to)
 	return err
 }
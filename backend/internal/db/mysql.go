@@ -0,0 +1,121 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// OpenMySQL 连接 MySQL 并建好账号相关的表（users、refresh_tokens、sessions、households、invitations），
+// 用于把账号放在已有 MySQL 中的部署；文件元数据始终在 SQLite。
+// 表结构与 SQLite 中的一致，兼容之前由 GORM 建出的 users 表
+func OpenMySQL(dsn string) (*sql.DB, error) {
+	// 时间列需要解析为 time.Time
+	if !strings.Contains(dsn, "parseTime=") {
+		if strings.Contains(dsn, "?") {
+			dsn += "&parseTime=true"
+		} else {
+			dsn += "?parseTime=true"
+		}
+	}
+	mysqlConn, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := mysqlConn.Ping(); err != nil {
+		mysqlConn.Close()
+		return nil, err
+	}
+	if err := migrateMySQL(mysqlConn); err != nil {
+		mysqlConn.Close()
+		return nil, err
+	}
+	return mysqlConn, nil
+}
+
+func migrateMySQL(c *sql.DB) error {
+	statements := []string{`
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(191) NOT NULL UNIQUE,
+    email VARCHAR(191) NOT NULL UNIQUE,
+    password_hash VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'member',
+    household_id BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    created_at DATETIME(3) NULL,
+    updated_at DATETIME(3) NULL,
+    INDEX idx_users_household (household_id)
+) DEFAULT CHARSET=utf8mb4`, `
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    token VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    family VARCHAR(64) NOT NULL DEFAULT '',
+    created_at DATETIME(3) NULL,
+    expires_at DATETIME(3) NOT NULL,
+    revoked_at DATETIME(3) NULL,
+    INDEX idx_refresh_tokens_user (user_id),
+    INDEX idx_refresh_tokens_family (family)
+) DEFAULT CHARSET=utf8mb4`, `
+CREATE TABLE IF NOT EXISTS sessions (
+    id VARCHAR(64) PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    device_name VARCHAR(255) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    created_at DATETIME(3) NOT NULL,
+    last_seen_at DATETIME(3) NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    revoked_at DATETIME(3) NULL,
+    INDEX idx_sessions_user (user_id)
+) DEFAULT CHARSET=utf8mb4`, `
+CREATE TABLE IF NOT EXISTS households (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(191) NOT NULL,
+    created_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    created_at DATETIME(3) NOT NULL
+) DEFAULT CHARSET=utf8mb4`, `
+CREATE TABLE IF NOT EXISTS invitations (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(64) NOT NULL UNIQUE,
+    household_id BIGINT UNSIGNED NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'member',
+    created_by BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    created_at DATETIME(3) NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    max_uses INT NOT NULL DEFAULT 1,
+    uses INT NOT NULL DEFAULT 0,
+    revoked_at DATETIME(3) NULL,
+    INDEX idx_invitations_household (household_id)
+) DEFAULT CHARSET=utf8mb4`}
+	for _, s := range statements {
+		if _, err := c.Exec(s); err != nil {
+			return err
+		}
+	}
+
+	// 旧表补充新增列
+	columns := []struct{ table, column, def string }{
+		{"users", "role", "VARCHAR(16) NOT NULL DEFAULT 'member'"},
+		{"users", "household_id", "BIGINT UNSIGNED NOT NULL DEFAULT 0"},
+		{"refresh_tokens", "family", "VARCHAR(64) NOT NULL DEFAULT ''"},
+		{"refresh_tokens", "created_at", "DATETIME(3) NULL"},
+		{"refresh_tokens", "revoked_at", "DATETIME(3) NULL"},
+	}
+	for _, col := range columns {
+		var n int
+		err := c.QueryRow("SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?",
+			col.table, col.column).Scan(&n)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := c.Exec("ALTER TABLE " + col.table + " ADD COLUMN " + col.column + " " + col.def); err != nil {
+			return err
+		}
+	}
+	_, err := c.Exec("UPDATE refresh_tokens SET family = token WHERE family = ''")
+	return err
+}
@@ -0,0 +1,298 @@
+// Package exif 从 JPEG 照片中读取常用的 EXIF 信息：拍摄时间、相机型号、方向、尺寸和 GPS 坐标。
+// 只解析家庭相册用得到的几个标签，不依赖第三方库
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrNoExif 图片中没有 EXIF 信息
+var ErrNoExif = errors.New("exif: 没有 EXIF 信息")
+
+// Data 照片的 EXIF 信息，缺失的字段为零值
+type Data struct {
+	TakenAt     time.Time // 拍摄时间，没有时区信息时按 loc 解释
+	Make        string
+	Model       string
+	Orientation int // 1-8，缺失时为 0
+	Width       int // 像素尺寸（未按方向旋转）
+	Height      int
+	HasGPS      bool
+	Latitude    float64 // 南纬为负
+	Longitude   float64 // 西经为负
+}
+
+// Camera 相机名称：厂商加型号，型号里已带厂商名时不重复
+func (d *Data) Camera() string {
+	if d.Make == "" || strings.HasPrefix(strings.ToLower(d.Model), strings.ToLower(d.Make)) {
+		return d.Model
+	}
+	if d.Model == "" {
+		return d.Make
+	}
+	return d.Make + " " + d.Model
+}
+
+// 用到的标签
+const (
+	tagMake              = 0x010f
+	tagModel             = 0x0110
+	tagOrientation       = 0x0112
+	tagDateTime          = 0x0132
+	tagExifIFD           = 0x8769
+	tagGPSIFD            = 0x8825
+	tagDateTimeOriginal  = 0x9003
+	tagDateTimeDigitized = 0x9004
+	tagOffsetTimeOrig    = 0x9011
+	tagPixelXDimension   = 0xa002
+	tagPixelYDimension   = 0xa003
+	tagGPSLatitudeRef    = 0x0001
+	tagGPSLatitude       = 0x0002
+	tagGPSLongitudeRef   = 0x0003
+	tagGPSLongitude      = 0x0004
+)
+
+// 各数据类型一个分量的字节数
+var typeSizes = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// Decode 从 JPEG 中读取 EXIF 信息，拍摄时间没有时区时按 loc 解释
+func Decode(r io.Reader, loc *time.Location) (*Data, error) {
+	payload, err := findAPP1(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseTIFF(payload, loc)
+}
+
+// 找到 JPEG 中 Exif 的 APP1 段，返回其中的 TIFF 数据
+func findAPP1(r io.Reader) ([]byte, error) {
+	var marker [2]byte
+	if _, err := io.ReadFull(r, marker[:]); err != nil {
+		return nil, err
+	}
+	if marker != [2]byte{0xff, 0xd8} {
+		return nil, errors.New("exif: 不是 JPEG 文件")
+	}
+	for {
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, err
+		}
+		if marker[0] != 0xff {
+			return nil, errors.New("exif: JPEG 段格式错误")
+		}
+		// 图像数据开始或结束，之后不会再有 EXIF
+		if marker[1] == 0xda || marker[1] == 0xd9 {
+			return nil, ErrNoExif
+		}
+		// 填充字节
+		if marker[1] == 0xff {
+			continue
+		}
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, err
+		}
+		if length < 2 {
+			return nil, errors.New("exif: JPEG 段长度错误")
+		}
+		if marker[1] != 0xe1 {
+			if _, err := io.CopyN(io.Discard, r, int64(length)-2); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return nil, err
+		}
+		// APP1 也可能是 XMP，继续找
+		if bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return segment[6:], nil
+		}
+	}
+}
+
+// TIFF 结构的读取器，偏移量都相对 TIFF 头
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+// IFD 中的一项
+type entry struct {
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+func parseTIFF(data []byte, loc *time.Location) (*Data, error) {
+	if len(data) < 8 {
+		return nil, ErrNoExif
+	}
+	t := &tiffReader{data: data}
+	switch string(data[:2]) {
+	case "II":
+		t.order = binary.LittleEndian
+	case "MM":
+		t.order = binary.BigEndian
+	default:
+		return nil, errors.New("exif: TIFF 字节序错误")
+	}
+	if t.order.Uint16(data[2:]) != 42 {
+		return nil, errors.New("exif: TIFF 标识错误")
+	}
+
+	ifd0, err := t.readIFD(t.order.Uint32(data[4:]))
+	if err != nil {
+		return nil, err
+	}
+	d := &Data{
+		Make:        t.str(ifd0[tagMake]),
+		Model:       t.str(ifd0[tagModel]),
+		Orientation: t.int(ifd0[tagOrientation]),
+	}
+	if d.Orientation < 1 || d.Orientation > 8 {
+		d.Orientation = 0
+	}
+
+	taken := t.str(ifd0[tagDateTime])
+	offset := ""
+	if e, ok := ifd0[tagExifIFD]; ok {
+		sub, err := t.readIFD(uint32(t.int(e)))
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range []uint16{tagDateTimeOriginal, tagDateTimeDigitized} {
+			if s := t.str(sub[tag]); s != "" {
+				taken = s
+				break
+			}
+		}
+		offset = t.str(sub[tagOffsetTimeOrig])
+		d.Width = t.int(sub[tagPixelXDimension])
+		d.Height = t.int(sub[tagPixelYDimension])
+	}
+	d.TakenAt = parseDateTime(taken, offset, loc)
+
+	if e, ok := ifd0[tagGPSIFD]; ok {
+		gps, err := t.readIFD(uint32(t.int(e)))
+		if err != nil {
+			return nil, err
+		}
+		lat, latOK := t.degrees(gps[tagGPSLatitude])
+		lon, lonOK := t.degrees(gps[tagGPSLongitude])
+		if latOK && lonOK && !(lat == 0 && lon == 0) {
+			if strings.HasPrefix(t.str(gps[tagGPSLatitudeRef]), "S") {
+				lat = -lat
+			}
+			if strings.HasPrefix(t.str(gps[tagGPSLongitudeRef]), "W") {
+				lon = -lon
+			}
+			d.HasGPS, d.Latitude, d.Longitude = true, lat, lon
+		}
+	}
+	return d, nil
+}
+
+// 读取 offset 处的 IFD
+func (t *tiffReader) readIFD(offset uint32) (map[uint16]entry, error) {
+	if int64(offset)+2 > int64(len(t.data)) {
+		return nil, fmt.Errorf("exif: IFD 偏移 %d 越界", offset)
+	}
+	n := int(t.order.Uint16(t.data[offset:]))
+	entries := make(map[uint16]entry, n)
+	for i := 0; i < n; i++ {
+		p := int(offset) + 2 + i*12
+		if p+12 > len(t.data) {
+			return nil, errors.New("exif: IFD 越界")
+		}
+		tag := t.order.Uint16(t.data[p:])
+		e := entry{typ: t.order.Uint16(t.data[p+2:]), count: t.order.Uint32(t.data[p+4:])}
+		size, ok := typeSizes[e.typ]
+		if !ok {
+			continue
+		}
+		total := int64(size) * int64(e.count)
+		if total <= 4 {
+			e.value = t.data[p+8 : p+8+int(total)]
+		} else {
+			start := int64(t.order.Uint32(t.data[p+8:]))
+			if start+total > int64(len(t.data)) {
+				continue
+			}
+			e.value = t.data[start : start+total]
+		}
+		entries[tag] = e
+	}
+	return entries, nil
+}
+
+// ASCII 值，去掉结尾的 NUL 和空白
+func (t *tiffReader) str(e entry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	s := string(e.value)
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// SHORT 或 LONG 的第一个值
+func (t *tiffReader) int(e entry) int {
+	switch {
+	case e.typ == 3 && len(e.value) >= 2:
+		return int(t.order.Uint16(e.value))
+	case e.typ == 4 && len(e.value) >= 4:
+		return int(t.order.Uint32(e.value))
+	}
+	return 0
+}
+
+// 度、分、秒三个有理数换算成度
+func (t *tiffReader) degrees(e entry) (float64, bool) {
+	if e.typ != 5 || len(e.value) < 24 {
+		return 0, false
+	}
+	var v float64
+	for i, unit := range []float64{1, 60, 3600} {
+		num := t.order.Uint32(e.value[i*8:])
+		den := t.order.Uint32(e.value[i*8+4:])
+		if den == 0 {
+			if num == 0 {
+				continue
+			}
+			return 0, false
+		}
+		v += float64(num) / float64(den) / unit
+	}
+	if math.IsNaN(v) || v > 180 {
+		return 0, false
+	}
+	return v, true
+}
+
+// EXIF 时间格式为 "2006:01:02 15:04:05"，offset 形如 "+08:00"
+func parseDateTime(s, offset string, loc *time.Location) time.Time {
+	if s == "" || strings.HasPrefix(s, "0000") {
+		return time.Time{}
+	}
+	if offset != "" {
+		if t, err := time.Parse("2006:01:02 15:04:05-07:00", s+offset); err == nil {
+			return t
+		}
+	}
+	t, err := time.ParseInLocation("2006:01:02 15:04:05", s, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
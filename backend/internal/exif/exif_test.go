@@ -0,0 +1,284 @@
+package exif
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+// 按顺序拼出 TIFF 数据：先写子 IFD，最后写 IFD0 并把头部指向它
+type tiffBuilder struct {
+	order binary.ByteOrder
+	buf   []byte
+}
+
+type tiffEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte
+}
+
+func newTIFF(order binary.ByteOrder) *tiffBuilder {
+	b := &tiffBuilder{order: order, buf: make([]byte, 8)}
+	if order == binary.LittleEndian {
+		copy(b.buf, "II")
+	} else {
+		copy(b.buf, "MM")
+	}
+	order.PutUint16(b.buf[2:], 42)
+	return b
+}
+
+func (b *tiffBuilder) ascii(tag uint16, s string) tiffEntry {
+	return tiffEntry{tag: tag, typ: 2, count: uint32(len(s) + 1), value: append([]byte(s), 0)}
+}
+
+func (b *tiffBuilder) short(tag uint16, v uint16) tiffEntry {
+	value := make([]byte, 2)
+	b.order.PutUint16(value, v)
+	return tiffEntry{tag: tag, typ: 3, count: 1, value: value}
+}
+
+func (b *tiffBuilder) long(tag uint16, v uint32) tiffEntry {
+	value := make([]byte, 4)
+	b.order.PutUint32(value, v)
+	return tiffEntry{tag: tag, typ: 4, count: 1, value: value}
+}
+
+// 分子、分母交替给出的有理数
+func (b *tiffBuilder) rational(tag uint16, v ...uint32) tiffEntry {
+	value := make([]byte, 4*len(v))
+	for i, x := range v {
+		b.order.PutUint32(value[i*4:], x)
+	}
+	return tiffEntry{tag: tag, typ: 5, count: uint32(len(v) / 2), value: value}
+}
+
+// 追加一个 IFD，超过 4 字节的值紧跟在 IFD 之后，返回 IFD 的偏移
+func (b *tiffBuilder) ifd(entries ...tiffEntry) uint32 {
+	offset := len(b.buf)
+	data := offset + 2 + 12*len(entries) + 4
+	ifd := make([]byte, 2+12*len(entries)+4)
+	b.order.PutUint16(ifd, uint16(len(entries)))
+	var extra []byte
+	for i, e := range entries {
+		p := ifd[2+12*i:]
+		b.order.PutUint16(p, e.tag)
+		b.order.PutUint16(p[2:], e.typ)
+		b.order.PutUint32(p[4:], e.count)
+		if len(e.value) <= 4 {
+			copy(p[8:], e.value)
+		} else {
+			b.order.PutUint32(p[8:], uint32(data+len(extra)))
+			extra = append(extra, e.value...)
+		}
+	}
+	b.buf = append(append(b.buf, ifd...), extra...)
+	return uint32(offset)
+}
+
+// 把 offset 处的 IFD 作为 IFD0，返回完整的 TIFF 数据
+func (b *tiffBuilder) root(offset uint32) []byte {
+	b.order.PutUint32(b.buf[4:], offset)
+	return b.buf
+}
+
+// 把 TIFF 数据包进只有 APP1 段的 JPEG
+func wrapJPEG(tiff []byte) []byte {
+	var buf bytes.Buffer
+	buf.Write([]byte{0xff, 0xd8})
+	// 前面放一个其他的 APP 段，确认会被跳过
+	buf.Write([]byte{0xff, 0xe0, 0x00, 0x04, 'J', 'F'})
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	buf.Write([]byte{0xff, 0xe1})
+	binary.Write(&buf, binary.BigEndian, uint16(len(segment)+2))
+	buf.Write(segment)
+	buf.Write([]byte{0xff, 0xd9})
+	return buf.Bytes()
+}
+
+func TestDecodeOrientation(t *testing.T) {
+	tests := []struct {
+		value uint16
+		want  int
+	}{
+		{1, 1}, {2, 2}, {3, 3}, {4, 4}, {5, 5}, {6, 6}, {7, 7}, {8, 8},
+		// 超出范围的值视为缺失
+		{0, 0}, {9, 0}, {0xffff, 0},
+	}
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		for _, tt := range tests {
+			b := newTIFF(order)
+			tiff := b.root(b.ifd(b.ascii(tagMake, "Canon"), b.short(tagOrientation, tt.value)))
+			d, err := Decode(bytes.NewReader(wrapJPEG(tiff)), time.UTC)
+			if err != nil {
+				t.Fatalf("%v orientation %d: %v", order, tt.value, err)
+			}
+			if d.Orientation != tt.want || d.Make != "Canon" {
+				t.Errorf("%v orientation %d: got %d (make %q), want %d", order, tt.value, d.Orientation, d.Make, tt.want)
+			}
+		}
+	}
+}
+
+func TestDecodeGPS(t *testing.T) {
+	tests := []struct {
+		name             string
+		latRef, lonRef   string
+		lat, lon         []uint32
+		wantOK           bool
+		wantLat, wantLon float64
+	}{
+		{"north east", "N", "E", []uint32{39, 1, 54, 1, 264, 10}, []uint32{116, 1, 23, 1, 3000, 100}, true, 39.907333, 116.391667},
+		{"south west", "S", "W", []uint32{33, 1, 51, 1, 0, 1}, []uint32{151, 1, 12, 1, 36, 1}, true, -33.85, -151.21},
+		// 常见的写法：分和秒为 0/0
+		{"zero over zero", "N", "E", []uint32{31, 1, 0, 0, 0, 0}, []uint32{121, 2, 0, 0, 0, 0}, true, 31, 60.5},
+		{"zero denominator", "N", "E", []uint32{31, 0, 0, 1, 0, 1}, []uint32{121, 1, 0, 1, 0, 1}, false, 0, 0},
+		{"null island", "N", "E", []uint32{0, 1, 0, 1, 0, 1}, []uint32{0, 1, 0, 1, 0, 1}, false, 0, 0},
+		{"out of range", "N", "E", []uint32{200, 1, 0, 1, 0, 1}, []uint32{10, 1, 0, 1, 0, 1}, false, 0, 0},
+		{"too few values", "N", "E", []uint32{31, 1, 0, 1}, []uint32{121, 1, 0, 1, 0, 1}, false, 0, 0},
+	}
+	for _, tt := range tests {
+		b := newTIFF(binary.BigEndian)
+		gps := b.ifd(
+			b.ascii(tagGPSLatitudeRef, tt.latRef),
+			b.rational(tagGPSLatitude, tt.lat...),
+			b.ascii(tagGPSLongitudeRef, tt.lonRef),
+			b.rational(tagGPSLongitude, tt.lon...),
+		)
+		d, err := parseTIFF(b.root(b.ifd(b.long(tagGPSIFD, gps))), time.UTC)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if d.HasGPS != tt.wantOK {
+			t.Errorf("%s: HasGPS = %v, want %v", tt.name, d.HasGPS, tt.wantOK)
+			continue
+		}
+		if math.Abs(d.Latitude-tt.wantLat) > 1e-6 || math.Abs(d.Longitude-tt.wantLon) > 1e-6 {
+			t.Errorf("%s: got %f, %f; want %f, %f", tt.name, d.Latitude, d.Longitude, tt.wantLat, tt.wantLon)
+		}
+	}
+}
+
+func TestDecodeDateTime(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	tests := []struct {
+		name                     string
+		dateTime, original, zone string
+		want                     time.Time
+	}{
+		{"original without offset", "2020:01:01 00:00:00", "2023:05:01 14:30:05", "", time.Date(2023, 5, 1, 14, 30, 5, 0, loc)},
+		{"original with offset", "", "2023:05:01 14:30:05", "-07:00", time.Date(2023, 5, 1, 21, 30, 5, 0, time.UTC)},
+		{"invalid offset", "", "2023:05:01 14:30:05", "local", time.Date(2023, 5, 1, 14, 30, 5, 0, loc)},
+		{"only ifd0 datetime", "2021:12:31 23:59:59", "", "", time.Date(2021, 12, 31, 23, 59, 59, 0, loc)},
+		{"empty date", "", "0000:00:00 00:00:00", "+08:00", time.Time{}},
+		{"malformed", "", "2023-05-01 14:30:05", "", time.Time{}},
+	}
+	for _, tt := range tests {
+		b := newTIFF(binary.LittleEndian)
+		var sub []tiffEntry
+		if tt.original != "" {
+			sub = append(sub, b.ascii(tagDateTimeOriginal, tt.original))
+		}
+		if tt.zone != "" {
+			sub = append(sub, b.ascii(tagOffsetTimeOrig, tt.zone))
+		}
+		sub = append(sub, b.long(tagPixelXDimension, 4032), b.short(tagPixelYDimension, 3024))
+		exifIFD := b.ifd(sub...)
+		ifd0 := []tiffEntry{b.long(tagExifIFD, exifIFD)}
+		if tt.dateTime != "" {
+			ifd0 = append(ifd0, b.ascii(tagDateTime, tt.dateTime))
+		}
+		d, err := parseTIFF(b.root(b.ifd(ifd0...)), loc)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !d.TakenAt.Equal(tt.want) {
+			t.Errorf("%s: TakenAt = %v, want %v", tt.name, d.TakenAt, tt.want)
+		}
+		if d.Width != 4032 || d.Height != 3024 {
+			t.Errorf("%s: size %dx%d", tt.name, d.Width, d.Height)
+		}
+	}
+}
+
+func TestParseTIFFMalformed(t *testing.T) {
+	b := newTIFF(binary.LittleEndian)
+	valid := b.root(b.ifd(b.ascii(tagMake, "Apple"), b.ascii(tagModel, "iPhone 15 Pro"), b.short(tagOrientation, 6)))
+
+	// 声明 3 项，数据在第 2 项中途结束
+	truncated := newTIFF(binary.LittleEndian)
+	off := truncated.ifd(truncated.short(tagOrientation, 1), truncated.short(tagOrientation, 1), truncated.short(tagOrientation, 1))
+	truncatedData := truncated.root(off)[:off+2+12+6]
+
+	// 值的偏移越界时只忽略这一项
+	badValue := newTIFF(binary.LittleEndian)
+	badValueData := badValue.root(badValue.ifd(badValue.ascii(tagModel, "a long model name"), badValue.short(tagOrientation, 3)))
+	binary.LittleEndian.PutUint32(badValueData[8+2+8:], 1<<20)
+
+	// Exif 和 GPS 子 IFD 指回 IFD0 自己
+	loop := newTIFF(binary.BigEndian)
+	loopData := loop.root(loop.ifd(loop.ascii(tagMake, "Nikon"), loop.long(tagExifIFD, 8), loop.long(tagGPSIFD, 8)))
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr bool
+		check   func(d *Data) bool
+	}{
+		{"valid", valid, false, func(d *Data) bool { return d.Camera() == "Apple iPhone 15 Pro" && d.Orientation == 6 }},
+		{"too short", valid[:6], true, nil},
+		{"bad byte order", append([]byte("XX"), valid[2:]...), true, nil},
+		{"truncated ifd", truncatedData, true, nil},
+		{"ifd0 offset past end", newTIFF(binary.LittleEndian).root(1 << 20), true, nil},
+		{"ifd0 offset at end", newTIFF(binary.BigEndian).root(7), true, nil},
+		{"exif offset past end", func() []byte {
+			b := newTIFF(binary.LittleEndian)
+			return b.root(b.ifd(b.long(tagExifIFD, 0xfffffff0)))
+		}(), true, nil},
+		{"gps offset past end", func() []byte {
+			b := newTIFF(binary.BigEndian)
+			return b.root(b.ifd(b.long(tagGPSIFD, 0xffffffff)))
+		}(), true, nil},
+		{"value offset past end", badValueData, false, func(d *Data) bool { return d.Model == "" && d.Orientation == 3 }},
+		{"ifd loop", loopData, false, func(d *Data) bool { return d.Make == "Nikon" && !d.HasGPS }},
+	}
+	for _, tt := range tests {
+		d, err := parseTIFF(tt.data, time.UTC)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: want error, got %+v", tt.name, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !tt.check(d) {
+			t.Errorf("%s: unexpected result %+v", tt.name, d)
+		}
+	}
+}
+
+func TestDecodeNoExif(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"no app1", []byte{0xff, 0xd8, 0xff, 0xe0, 0x00, 0x04, 'J', 'F', 0xff, 0xda}, ErrNoExif},
+		{"xmp only", append([]byte{0xff, 0xd8, 0xff, 0xe1, 0x00, 0x07}, "http\x00\xff\xd9"...), ErrNoExif},
+	}
+	for _, tt := range tests {
+		if _, err := Decode(bytes.NewReader(tt.data), time.UTC); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if _, err := Decode(bytes.NewReader([]byte("GIF89a")), time.UTC); err == nil {
+		t.Error("not a jpeg: want error")
+	}
+}
@@ -0,0 +1,74 @@
+package fulltext
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+// document.xml 解压后的大小上限，防止压缩炸弹
+const maxDocxXML = 64 << 20
+
+// Word 文档的正文在 word/document.xml 中，文字都在 <w:t> 里，<w:p> 是段落
+func extractDocx(r io.ReaderAt, size int64) (string, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return "", ErrUnsupported
+	}
+	var doc *zip.File
+	for _, f := range zr.File {
+		if f.Name == "word/document.xml" {
+			doc = f
+			break
+		}
+	}
+	if doc == nil {
+		return "", ErrUnsupported
+	}
+	rc, err := doc.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	var out textBuilder
+	dec := xml.NewDecoder(io.LimitReader(rc, maxDocxXML))
+	inText := false
+	for !out.full() {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			// 文档损坏或被截断时保留已经取到的文字
+			if out.Len() == 0 {
+				return "", ErrUnsupported
+			}
+			break
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				out.add("\t")
+			case "br", "cr":
+				out.add("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				out.add("\n")
+			}
+		case xml.CharData:
+			if inText {
+				out.add(string(t))
+			}
+		}
+	}
+	return normalize(out.String()), nil
+}
@@ -0,0 +1,126 @@
+// Package fulltext 从文档中提取全文搜索用的纯文本：纯文本、Markdown、PDF 和 Word（docx）。
+// 只取文字，不保留排版，不依赖第三方库
+package fulltext
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// 文档种类
+const (
+	KindText = "text"
+	KindPDF  = "pdf"
+	KindDocx = "docx"
+)
+
+// MaxText 提取结果的最大字节数，超出部分丢弃
+const MaxText = 1 << 20
+
+// ErrUnsupported 文件类型不支持或内容无法识别
+var ErrUnsupported = errors.New("fulltext: 不支持的文件内容")
+
+// 按内容类型识别的文档种类
+var kindsByType = map[string]string{
+	"text/plain":      KindText,
+	"text/markdown":   KindText,
+	"text/x-markdown": KindText,
+	"application/pdf": KindPDF,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": KindDocx,
+}
+
+// 内容类型不明确（如 application/octet-stream）时按扩展名识别
+var kindsByExt = map[string]string{
+	".txt":      KindText,
+	".md":       KindText,
+	".markdown": KindText,
+	".pdf":      KindPDF,
+	".docx":     KindDocx,
+}
+
+// KindOf 按内容类型和文件名判断文档种类，不能提取文本时返回空字符串
+func KindOf(contentType, name string) string {
+	t := contentType
+	if i := strings.IndexByte(t, ';'); i >= 0 {
+		t = t[:i]
+	}
+	if kind, ok := kindsByType[strings.TrimSpace(strings.ToLower(t))]; ok {
+		return kind
+	}
+	return kindsByExt[strings.ToLower(path.Ext(name))]
+}
+
+// Extract 提取 r（长度为 size）中的文本，结果不超过 MaxText 字节
+func Extract(kind string, r io.ReaderAt, size int64) (string, error) {
+	switch kind {
+	case KindText:
+		return extractText(r, size)
+	case KindPDF:
+		return extractPDF(r, size)
+	case KindDocx:
+		return extractDocx(r, size)
+	}
+	return "", ErrUnsupported
+}
+
+// 纯文本只取开头 MaxText 字节，含有 NUL 的视为二进制文件
+func extractText(r io.ReaderAt, size int64) (string, error) {
+	buf := make([]byte, min(size, MaxText))
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	buf = buf[:n]
+	if bytes.IndexByte(buf, 0) >= 0 {
+		return "", ErrUnsupported
+	}
+	s := strings.TrimPrefix(string(buf), "\ufeff")
+	return normalize(strings.ToValidUTF8(s, "")), nil
+}
+
+// 收集提取出的文字，超过 MaxText 后不再追加
+type textBuilder struct {
+	strings.Builder
+}
+
+func (b *textBuilder) full() bool {
+	return b.Len() >= MaxText
+}
+
+func (b *textBuilder) add(s string) {
+	if !b.full() {
+		b.WriteString(s)
+	}
+}
+
+// 去掉每行首尾和连续的空白，删除空行，并截断到 MaxText 字节以内
+func normalize(s string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(strings.Join(fields, " "))
+		if b.Len() >= MaxText {
+			break
+		}
+	}
+	out := b.String()
+	if len(out) <= MaxText {
+		return out
+	}
+	// 不在多字节字符中间截断
+	out = out[:MaxText]
+	for len(out) > 0 && !utf8.ValidString(out) {
+		out = out[:len(out)-1]
+	}
+	return out
+}
@@ -0,0 +1,273 @@
+package fulltext
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	// 超过这个大小的 PDF 不提取，整个文件要读进内存
+	maxPDF = 64 << 20
+	// 单个流解压后的大小上限
+	maxPDFStream = 16 << 20
+)
+
+// 不含正文的流：图片、字体、交叉引用、元数据等
+var skippedStreams = []string{"/Image", "/FontFile", "/Length1", "/XRef", "/ObjStm", "/Metadata", "/EmbeddedFile"}
+
+// PDF 的文字在页面内容流的 Tj、TJ、'、" 操作符中。这里逐个解压 FlateDecode 流并取出这些字符串，
+// 只能识别按单字节编码（PDFDocEncoding、WinAnsi 等）或 UTF-16 写入的文字，
+// 使用自定义 CID 字体（多见于中文 PDF）的文字无法还原，会被跳过
+func extractPDF(r io.ReaderAt, size int64) (string, error) {
+	if size > maxPDF {
+		return "", ErrUnsupported
+	}
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, 0); err != nil && err != io.EOF {
+		return "", err
+	}
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", ErrUnsupported
+	}
+
+	var out textBuilder
+	for pos := 0; !out.full(); {
+		start, dict := nextStream(data, pos)
+		if start < 0 {
+			break
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		pos = start + end + len("endstream")
+		if content := decodeStream(dict, data[start:start+end]); content != nil {
+			showText(content, &out)
+		}
+	}
+	return normalize(out.String()), nil
+}
+
+// 从 pos 开始找下一个流，返回流数据的起点和它的字典，没有时起点为 -1
+func nextStream(data []byte, pos int) (int, []byte) {
+	for {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i < 0 {
+			return -1, nil
+		}
+		i += pos
+		pos = i + len("stream")
+		// 跳过 endstream
+		if i >= 3 && string(data[i-3:i]) == "end" {
+			continue
+		}
+		start := pos
+		if start < len(data) && data[start] == '\r' {
+			start++
+		}
+		if start < len(data) && data[start] == '\n' {
+			start++
+		}
+		dictStart := bytes.LastIndex(data[:i], []byte("obj"))
+		if dictStart < 0 {
+			dictStart = 0
+		}
+		return start, data[dictStart:i]
+	}
+}
+
+// 按字典中的 Filter 解出流的内容，不含正文或无法解码时返回 nil
+func decodeStream(dict, raw []byte) []byte {
+	for _, s := range skippedStreams {
+		if bytes.Contains(dict, []byte(s)) {
+			return nil
+		}
+	}
+	if !bytes.Contains(dict, []byte("/Filter")) {
+		return raw
+	}
+	// 只支持最常见的 FlateDecode，且不能再叠加其他过滤器
+	if !bytes.Contains(dict, []byte("/FlateDecode")) || bytes.Count(dict, []byte("Decode")) > 1 {
+		return nil
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+	content, _ := io.ReadAll(io.LimitReader(zr, maxPDFStream))
+	return content
+}
+
+// 内容流中的分隔符
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0 || isSpace(c)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+// 解析内容流，把显示文字的操作符的字符串写入 out
+func showText(content []byte, out *textBuilder) {
+	var pending []string
+	for i := 0; i < len(content) && !out.full(); {
+		c := content[i]
+		switch {
+		case isSpace(c) || c == '[' || c == ']' || c == '{' || c == '}' || c == '>':
+			i++
+		case c == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, n := literalString(content[i:])
+			pending = append(pending, s)
+			i += n
+		case c == '<' && i+1 < len(content) && content[i+1] == '<':
+			i += 2
+		case c == '<':
+			s, n := hexString(content[i:])
+			pending = append(pending, s)
+			i += n
+		default:
+			j := i + 1
+			for j < len(content) && !isDelimiter(content[j]) {
+				j++
+			}
+			token := string(content[i:j])
+			i = j
+			if c == '/' {
+				continue
+			}
+			// TJ 数组中较大的负间距通常是词间空格
+			if n, err := strconv.ParseFloat(token, 64); err == nil {
+				if n < -180 && len(pending) > 0 {
+					pending = append(pending, " ")
+				}
+				continue
+			}
+			switch token {
+			case "Tj", "TJ":
+				out.add(decodeText(pending))
+			case "'", "\"":
+				out.add("\n" + decodeText(pending))
+			case "Td", "TD":
+				out.add(" ")
+			case "T*", "ET":
+				out.add("\n")
+			}
+			pending = pending[:0]
+		}
+	}
+}
+
+// 把字符串转换为文字：带 BOM 的按 UTF-16BE，其他按单字节编码；
+// 含有控制字符的多半是 CID 字体的字形编号，丢弃
+func decodeText(parts []string) string {
+	var b strings.Builder
+	for _, s := range parts {
+		if strings.HasPrefix(s, "\xfe\xff") {
+			u := make([]uint16, 0, len(s)/2)
+			for i := 2; i+1 < len(s); i += 2 {
+				u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+			}
+			b.WriteString(string(utf16.Decode(u)))
+			continue
+		}
+		if strings.IndexFunc(s, func(r rune) bool { return r < 0x20 && r != '\t' && r != '\n' && r != '\r' }) >= 0 {
+			continue
+		}
+		for i := 0; i < len(s); i++ {
+			b.WriteRune(rune(s[i]))
+		}
+	}
+	return b.String()
+}
+
+// 解析 ( 开头的字面字符串，返回内容和消耗的字节数
+func literalString(data []byte) (string, int) {
+	var b []byte
+	depth := 0
+	i := 0
+	for i < len(data) {
+		c := data[i]
+		i++
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return string(b), i
+			}
+		case '\\':
+			if i >= len(data) {
+				return string(b), i
+			}
+			e := data[i]
+			i++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// 续行
+				if e == '\r' && i < len(data) && data[i] == '\n' {
+					i++
+				}
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && i < len(data) && data[i] >= '0' && data[i] <= '7'; k++ {
+						v = v*8 + int(data[i]-'0')
+						i++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		b = append(b, c)
+	}
+	return string(b), i
+}
+
+// 解析 <...> 十六进制字符串，返回内容和消耗的字节数
+func hexString(data []byte) (string, int) {
+	end := bytes.IndexByte(data, '>')
+	if end < 0 {
+		end = len(data) - 1
+	}
+	var digits []byte
+	for _, c := range data[1:end] {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b := make([]byte, len(digits)/2)
+	for i := range b {
+		v, _ := strconv.ParseUint(string(digits[i*2:i*2+2]), 16, 8)
+		b[i] = byte(v)
+	}
+	return string(b), end + 1
+}
@@ -0,0 +1,250 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Album 相册：按顺序引用已有的文件，不复制内容
+type Album struct {
+	ID          int64     `json:"id"`
+	OwnerID     int64     `json:"owner_id"`
+	SpaceID     int64     `json:"space_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CoverFileID int64     `json:"cover_file_id"` // 指定的封面，0 表示用第一张图片
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	// 查询时计算：未删除的条目数和实际使用的封面（0 表示相册为空）
+	Count int   `json:"count"`
+	Cover int64 `json:"cover"`
+}
+
+// 相册中未删除的条目
+const liveAlbumItems = "album_items i JOIN files f ON f.id = i.file_id AND f.trash_id = 0 WHERE i.album_id = a.id"
+
+const albumColumns = "a.id,a.owner_id,a.space_id,a.name,a.description,a.cover_file_id,a.created_at,a.updated_at," +
+	"(SELECT COUNT(*) FROM " + liveAlbumItems + ")," +
+	"COALESCE((SELECT i.file_id FROM " + liveAlbumItems + " AND i.file_id = a.cover_file_id)," +
+	"(SELECT i.file_id FROM " + liveAlbumItems + " ORDER BY f.content_type NOT LIKE 'image/%', i.position, i.file_id LIMIT 1), 0)"
+
+// AlbumRepository 负责 albums、album_items 表的读写
+type AlbumRepository struct {
+	db *sql.DB
+}
+
+func NewAlbumRepository(conn *sql.DB) *AlbumRepository {
+	return &AlbumRepository{db: conn}
+}
+
+func scanAlbum(row rowScanner) (*Album, error) {
+	var a Album
+	err := row.Scan(&a.ID, &a.OwnerID, &a.SpaceID, &a.Name, &a.Description, &a.CoverFileID, &a.CreatedAt, &a.UpdatedAt, &a.Count, &a.Cover)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// Create 插入新相册，并回填 ID
+func (r *AlbumRepository) Create(a *Album) error {
+	now := time.Now()
+	a.CreatedAt, a.UpdatedAt = now, now
+	res, err := r.db.Exec(
+		"INSERT INTO albums(owner_id,space_id,name,description,cover_file_id,created_at,updated_at) VALUES(?,?,?,?,?,?,?)",
+		a.OwnerID, a.SpaceID, a.Name, a.Description, a.CoverFileID, a.CreatedAt, a.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	a.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetByID 按 ID 查询，不存在时返回 nil, nil
+func (r *AlbumRepository) GetByID(id int64) (*Album, error) {
+	a, err := scanAlbum(r.db.QueryRow("SELECT "+albumColumns+" FROM albums a WHERE a.id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// ListBySpaces 列出若干空间中的相册，最近更新的在前
+func (r *AlbumRepository) ListBySpaces(spaceIDs []int64) ([]Album, error) {
+	list := []Album{}
+	if len(spaceIDs) == 0 {
+		return list, nil
+	}
+	in, args := inPlaceholders(spaceIDs)
+	rows, err := r.db.Query("SELECT "+albumColumns+" FROM albums a WHERE a.space_id IN ("+in+") ORDER BY a.updated_at DESC, a.id DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a, err := scanAlbum(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *a)
+	}
+	return list, rows.Err()
+}
+
+// Update 修改名称、描述和封面
+func (r *AlbumRepository) Update(a *Album) error {
+	a.UpdatedAt = time.Now()
+	_, err := r.db.Exec("UPDATE albums SET name = ?, description = ?, cover_file_id = ?, updated_at = ? WHERE id = ?",
+		a.Name, a.Description, a.CoverFileID, a.UpdatedAt, a.ID)
+	return err
+}
+
+// Delete 删除相册及其条目，引用的文件不受影响
+func (r *AlbumRepository) Delete(id int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM album_items WHERE album_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM albums WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Files 按相册顺序列出未删除的文件
+func (r *AlbumRepository) Files(albumID int64) ([]File, error) {
+	rows, err := r.db.Query("SELECT "+prefixColumns("f", fileColumns)+" FROM album_items i JOIN files f ON f.id = i.file_id AND f.trash_id = 0 WHERE i.album_id = ? ORDER BY i.position, i.file_id", albumID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []File{}
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *f)
+	}
+	return list, rows.Err()
+}
+
+// HasFile 文件是否在相册中
+func (r *AlbumRepository) HasFile(albumID, fileID int64) (bool, error) {
+	var n int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM album_items WHERE album_id = ? AND file_id = ?", albumID, fileID).Scan(&n)
+	return n > 0, err
+}
+
+// AddFiles 把文件依次加到相册末尾，已在相册中的跳过；返回新加入的数量
+func (r *AlbumRepository) AddFiles(albumID int64, fileIDs []int64) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var last int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(position), 0) FROM album_items WHERE album_id = ?", albumID).Scan(&last); err != nil {
+		return 0, err
+	}
+	added := 0
+	now := time.Now()
+	for _, id := range fileIDs {
+		res, err := tx.Exec("INSERT OR IGNORE INTO album_items(album_id,file_id,position,added_at) VALUES(?,?,?,?)", albumID, id, last+added+1, now)
+		if err != nil {
+			return 0, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+	if _, err := tx.Exec("UPDATE albums SET updated_at = ? WHERE id = ?", now, albumID); err != nil {
+		return 0, err
+	}
+	return added, tx.Commit()
+}
+
+// RemoveFiles 从相册中移除文件
+func (r *AlbumRepository) RemoveFiles(albumID int64, fileIDs []int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range fileIDs {
+		if _, err := tx.Exec("DELETE FROM album_items WHERE album_id = ? AND file_id = ?", albumID, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE albums SET updated_at = ? WHERE id = ?", time.Now(), albumID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Reorder 按 fileIDs 的顺序重排，未列出的条目保持原有顺序排在后面
+func (r *AlbumRepository) Reorder(albumID int64, fileIDs []int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT file_id FROM album_items WHERE album_id = ? ORDER BY position, file_id", albumID)
+	if err != nil {
+		return err
+	}
+	var current []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current = append(current, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	listed := map[int64]bool{}
+	order := make([]int64, 0, len(current))
+	for _, id := range fileIDs {
+		if !listed[id] {
+			listed[id] = true
+			order = append(order, id)
+		}
+	}
+	for _, id := range current {
+		if !listed[id] {
+			order = append(order, id)
+		}
+	}
+	for i, id := range order {
+		if _, err := tx.Exec("UPDATE album_items SET position = ? WHERE album_id = ? AND file_id = ?", i+1, albumID, id); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE albums SET updated_at = ? WHERE id = ?", time.Now(), albumID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RemoveFileEverywhere 文件被彻底删除时，从所有相册中移除
+func (r *AlbumRepository) RemoveFileEverywhere(fileID int64) error {
+	_, err := r.db.Exec("DELETE FROM album_items WHERE file_id = ?", fileID)
+	return err
+}
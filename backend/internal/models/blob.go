@@ -0,0 +1,99 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Blob 内容寻址存储中的一份内容，以 sha256 为键。
+// RefCount 为引用它的文件和历史版本数，降到 0 时内容可以删除
+type Blob struct {
+	Hash      string    `json:"hash"`
+	Size      int64     `json:"size"`
+	RefCount  int64     `json:"ref_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlobStats 内容存储的汇总：LogicalSize 为按引用计算的总大小，PhysicalSize 为实际占用
+type BlobStats struct {
+	Blobs        int64 `json:"blobs"`
+	References   int64 `json:"references"`
+	LogicalSize  int64 `json:"logical_size"`
+	PhysicalSize int64 `json:"physical_size"`
+}
+
+// BlobRepository 负责 blobs 表的读写
+type BlobRepository struct {
+	db *sql.DB
+}
+
+func NewBlobRepository(conn *sql.DB) *BlobRepository {
+	return &BlobRepository{db: conn}
+}
+
+// Get 按 sha256 查询，不存在时返回 nil, nil
+func (r *BlobRepository) Get(hash string) (*Blob, error) {
+	var b Blob
+	err := r.db.QueryRow("SELECT hash,size,ref_count,created_at FROM blobs WHERE hash = ?", hash).
+		Scan(&b.Hash, &b.Size, &b.RefCount, &b.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Acquire 增加一次引用，内容第一次出现时插入记录
+func (r *BlobRepository) Acquire(hash string, size int64) error {
+	_, err := r.db.Exec(
+		"INSERT INTO blobs(hash,size,ref_count,created_at) VALUES(?,?,1,?) ON CONFLICT(hash) DO UPDATE SET ref_count = ref_count + 1",
+		hash, size, time.Now(),
+	)
+	return err
+}
+
+// Release 减少一次引用，返回剩余引用数；记录不存在时返回 0
+func (r *BlobRepository) Release(hash string) (int64, error) {
+	if _, err := r.db.Exec("UPDATE blobs SET ref_count = ref_count - 1 WHERE hash = ? AND ref_count > 0", hash); err != nil {
+		return 0, err
+	}
+	var n int64
+	err := r.db.QueryRow("SELECT ref_count FROM blobs WHERE hash = ?", hash).Scan(&n)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return n, err
+}
+
+// Delete 删除记录
+func (r *BlobRepository) Delete(hash string) error {
+	_, err := r.db.Exec("DELETE FROM blobs WHERE hash = ?", hash)
+	return err
+}
+
+// HouseholdStats 汇总一个家庭引用的内容：计入 ownerIDs（家庭成员）名下的文件和归档的历史版本，
+// 同一份内容在家庭内多次引用时只计一次实际占用
+func (r *BlobRepository) HouseholdStats(ownerIDs []int64) (*BlobStats, error) {
+	var s BlobStats
+	if len(ownerIDs) == 0 {
+		return &s, nil
+	}
+	in, args := inPlaceholders(ownerIDs)
+	err := r.db.QueryRow(`
+WITH refs AS (
+    SELECT blob AS hash FROM files WHERE owner_id IN (`+in+`) AND blob <> ''
+    UNION ALL
+    SELECT checksum FROM file_versions WHERE uploader_id IN (`+in+`) AND path <> ''
+)
+SELECT COUNT(DISTINCT b.hash), COUNT(*), COALESCE(SUM(b.size),0),
+    COALESCE((SELECT SUM(size) FROM blobs WHERE hash IN (SELECT hash FROM refs)),0)
+FROM refs JOIN blobs b ON b.hash = refs.hash`,
+		append(args, args...)...,
+	).Scan(&s.Blobs, &s.References, &s.LogicalSize, &s.PhysicalSize)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// 全文索引的状态
+const (
+	ContentPending = "pending"
+	ContentReady   = "ready"
+	ContentFailed  = "failed"
+)
+
+// ContentIndex blob 的全文索引记录，同一内容只提取一次。Kind 为文档种类（见 fulltext 包）
+type ContentIndex struct {
+	Blob      string    `json:"blob"`
+	Kind      string    `json:"kind"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const contentColumns = "blob,kind,status,created_at,updated_at"
+
+// ContentRepository 负责 content_index 表和全文表 content_text 的读写
+type ContentRepository struct {
+	db *sql.DB
+}
+
+func NewContentRepository(conn *sql.DB) *ContentRepository {
+	return &ContentRepository{db: conn}
+}
+
+// Enqueue 登记待提取文本的 blob，已有记录时不变；返回是否新登记
+func (r *ContentRepository) Enqueue(blob, kind string) (bool, error) {
+	now := time.Now()
+	res, err := r.db.Exec("INSERT OR IGNORE INTO content_index(blob,kind,status,created_at,updated_at) VALUES(?,?,?,?,?)",
+		blob, kind, ContentPending, now, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// EnqueueAll 批量登记，blobs 为 blob 到文档种类的映射，返回新登记的条数
+func (r *ContentRepository) EnqueueAll(blobs map[string]string) (int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	added := 0
+	for blob, kind := range blobs {
+		res, err := tx.Exec("INSERT OR IGNORE INTO content_index(blob,kind,status,created_at,updated_at) VALUES(?,?,?,?,?)",
+			blob, kind, ContentPending, now, now)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		added += int(n)
+	}
+	return added, tx.Commit()
+}
+
+// ListPending 按登记顺序列出待提取的记录
+func (r *ContentRepository) ListPending(limit int) ([]ContentIndex, error) {
+	rows, err := r.db.Query("SELECT "+contentColumns+" FROM content_index WHERE status = ? ORDER BY created_at, blob LIMIT ?", ContentPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []ContentIndex{}
+	for rows.Next() {
+		var ci ContentIndex
+		if err := rows.Scan(&ci.Blob, &ci.Kind, &ci.Status, &ci.CreatedAt, &ci.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, ci)
+	}
+	return list, rows.Err()
+}
+
+// SetText 保存提取出的文本并标记为已索引
+func (r *ContentRepository) SetText(blob, text string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM content_text WHERE blob = ?", blob); err != nil {
+		return err
+	}
+	if text != "" {
+		if _, err := tx.Exec("INSERT INTO content_text(blob, body) VALUES(?,?)", blob, text); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("UPDATE content_index SET status = ?, updated_at = ? WHERE blob = ?", ContentReady, time.Now(), blob); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkFailed 记录无法提取文本（文件损坏、格式不支持等），之后不再重试
+func (r *ContentRepository) MarkFailed(blob string) error {
+	_, err := r.db.Exec("UPDATE content_index SET status = ?, updated_at = ? WHERE blob = ?", ContentFailed, time.Now(), blob)
+	return err
+}
+
+// Delete 删除 blob 的索引记录和文本
+func (r *ContentRepository) Delete(blob string) error {
+	if _, err := r.db.Exec("DELETE FROM content_text WHERE blob = ?", blob); err != nil {
+		return err
+	}
+	_, err := r.db.Exec("DELETE FROM content_index WHERE blob = ?", blob)
+	return err
+}
+
+// 转义 LIKE 中的通配符
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Match 在 spaceIDs 中未删除文件的内容里查找包含全部 terms（不区分大小写）的 blob，
+// 返回 blob 到命中位置附近摘录的映射，最多 limit 条。
+// content_text 使用 trigram 分词，三个字符以上的词走索引
+func (r *ContentRepository) Match(terms []string, spaceIDs []int64, limit int) (map[string]string, error) {
+	matches := map[string]string{}
+	if len(terms) == 0 || len(spaceIDs) == 0 {
+		return matches, nil
+	}
+	in, spaceArgs := inPlaceholders(spaceIDs)
+	where := make([]string, len(terms))
+	args := append([]interface{}{terms[0]}, spaceArgs...)
+	for i, t := range terms {
+		where[i] = `body LIKE ? ESCAPE '\'`
+		args = append(args, "%"+likeEscaper.Replace(t)+"%")
+	}
+	args = append(args, limit)
+	rows, err := r.db.Query(`
+SELECT blob, substr(body, max(instr(lower(body), lower(?)) - 30, 1), 120)
+FROM content_text
+WHERE blob IN (SELECT blob FROM files WHERE space_id IN (`+in+`) AND trash_id = 0)
+    AND `+strings.Join(where, " AND ")+` LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var blob, snippet string
+		if err := rows.Scan(&blob, &snippet); err != nil {
+			return nil, err
+		}
+		matches[blob] = snippet
+	}
+	return matches, rows.Err()
+}
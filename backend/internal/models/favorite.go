@@ -0,0 +1,55 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// FavoriteRepository 负责 favorites 表的读写：每个成员各自收藏的文件
+type FavoriteRepository struct {
+	db *sql.DB
+}
+
+func NewFavoriteRepository(conn *sql.DB) *FavoriteRepository {
+	return &FavoriteRepository{db: conn}
+}
+
+// Add 收藏文件，已收藏时不变
+func (r *FavoriteRepository) Add(userID, fileID int64) error {
+	_, err := r.db.Exec("INSERT OR IGNORE INTO favorites(user_id,file_id,created_at) VALUES(?,?,?)", userID, fileID, time.Now())
+	return err
+}
+
+// Remove 取消收藏
+func (r *FavoriteRepository) Remove(userID, fileID int64) error {
+	_, err := r.db.Exec("DELETE FROM favorites WHERE user_id = ? AND file_id = ?", userID, fileID)
+	return err
+}
+
+// Of 返回 fileIDs 中被用户收藏的集合
+func (r *FavoriteRepository) Of(userID int64, fileIDs []int64) (map[int64]bool, error) {
+	favorites := map[int64]bool{}
+	if len(fileIDs) == 0 {
+		return favorites, nil
+	}
+	in, args := inPlaceholders(fileIDs)
+	rows, err := r.db.Query("SELECT file_id FROM favorites WHERE user_id = ? AND file_id IN ("+in+")", append([]interface{}{userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		favorites[id] = true
+	}
+	return favorites, rows.Err()
+}
+
+// RemoveFileEverywhere 文件被彻底删除时清除所有人的收藏
+func (r *FavoriteRepository) RemoveFileEverywhere(fileID int64) error {
+	_, err := r.db.Exec("DELETE FROM favorites WHERE file_id = ?", fileID)
+	return err
+}
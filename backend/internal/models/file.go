@@ -0,0 +1,297 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// File 文件元数据记录
+type File struct {
+	ID          int64     `json:"id"`
+	OwnerID     int64     `json:"owner_id"` // 0 表示启动时从磁盘收养、归属未知
+	SpaceID     int64     `json:"space_id"`
+	FolderID    int64     `json:"folder_id"` // 0 表示根目录
+	Name        string    `json:"name"`
+	Path        string    `json:"path"` // 逻辑存储路径，同一时刻唯一
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	Checksum    string    `json:"checksum"` // sha256 十六进制
+	Blob        string    `json:"blob"`     // 内容所在 blob 的 sha256，为空表示内容还按 Path 直接存放
+	IsHidden    bool      `json:"is_hidden"`
+	TrashID     int64     `json:"trash_id"` // 非 0 表示已移入回收站
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Photo       PhotoMeta `json:"photo"`
+}
+
+// PhotoMeta 上传时从照片 EXIF 中读取的信息
+type PhotoMeta struct {
+	TakenAt     *time.Time `json:"taken_at"` // 拍摄时间，EXIF 中没有时为 nil
+	Camera      string     `json:"camera"`
+	Orientation int        `json:"orientation"` // EXIF 方向 1-8，0 表示还没有解析过
+	Width       int        `json:"width"`       // 按方向旋转后的显示尺寸
+	Height      int        `json:"height"`
+	Latitude    *float64   `json:"latitude"`
+	Longitude   *float64   `json:"longitude"`
+}
+
+const fileColumns = "id,owner_id,space_id,folder_id,name,path,size,content_type,checksum,blob,is_hidden,trash_id,created_at,updated_at," +
+	"taken_at,camera,orientation,width,height,latitude,longitude"
+
+// FileRepository 负责 files 表的读写
+type FileRepository struct {
+	db *sql.DB
+}
+
+func NewFileRepository(conn *sql.DB) *FileRepository {
+	return &FileRepository{db: conn}
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// prefixColumns 给逗号分隔的列名加上表别名前缀
+func prefixColumns(alias, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, p := range parts {
+		parts[i] = alias + "." + p
+	}
+	return strings.Join(parts, ",")
+}
+
+// inPlaceholders 为 IN 子句生成占位符和参数
+func inPlaceholders[T int64 | string](values []T) (string, []interface{}) {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return strings.TrimSuffix(strings.Repeat("?,", len(values)), ","), args
+}
+
+func scanFile(row rowScanner) (*File, error) {
+	var (
+		f        File
+		takenAt  sql.NullTime
+		lat, lon sql.NullFloat64
+	)
+	err := row.Scan(&f.ID, &f.OwnerID, &f.SpaceID, &f.FolderID, &f.Name, &f.Path, &f.Size, &f.ContentType,
+		&f.Checksum, &f.Blob, &f.IsHidden, &f.TrashID, &f.CreatedAt, &f.UpdatedAt,
+		&takenAt, &f.Photo.Camera, &f.Photo.Orientation, &f.Photo.Width, &f.Photo.Height, &lat, &lon)
+	if err != nil {
+		return nil, err
+	}
+	if takenAt.Valid {
+		f.Photo.TakenAt = &takenAt.Time
+	}
+	if lat.Valid && lon.Valid {
+		f.Photo.Latitude, f.Photo.Longitude = &lat.Float64, &lon.Float64
+	}
+	return &f, nil
+}
+
+// Create 插入新记录，并回填 ID
+func (r *FileRepository) Create(f *File) error {
+	now := time.Now()
+	if f.CreatedAt.IsZero() {
+		f.CreatedAt = now
+	}
+	if f.UpdatedAt.IsZero() {
+		f.UpdatedAt = f.CreatedAt
+	}
+	res, err := r.db.Exec(
+		"INSERT INTO files(owner_id,space_id,folder_id,name,path,size,content_type,checksum,blob,is_hidden,trash_id,created_at,updated_at) VALUES(?,?,?,?,?,?,?,?,?,?,?,?,?)",
+		f.OwnerID, f.SpaceID, f.FolderID, f.Name, f.Path, f.Size, f.ContentType, f.Checksum, f.Blob, f.IsHidden, f.TrashID, f.CreatedAt, f.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	f.ID, err = res.LastInsertId()
+	return err
+}
+
+// Update 更新内容相关字段（覆盖上传、磁盘变化时使用）
+func (r *FileRepository) Update(f *File) error {
+	f.UpdatedAt = time.Now()
+	_, err := r.db.Exec(
+		"UPDATE files SET owner_id=?,space_id=?,folder_id=?,name=?,path=?,size=?,content_type=?,checksum=?,blob=?,is_hidden=?,trash_id=?,updated_at=? WHERE id=?",
+		f.OwnerID, f.SpaceID, f.FolderID, f.Name, f.Path, f.Size, f.ContentType, f.Checksum, f.Blob, f.IsHidden, f.TrashID, f.UpdatedAt, f.ID,
+	)
+	return err
+}
+
+// SetBlob 记录文件内容所在的 blob，不改变更新时间（迁移旧数据时使用）
+func (r *FileRepository) SetBlob(f *File) error {
+	_, err := r.db.Exec("UPDATE files SET blob=?,checksum=?,size=? WHERE id=?", f.Blob, f.Checksum, f.Size, f.ID)
+	return err
+}
+
+// SetPhotoMeta 记录照片信息，不改变更新时间
+func (r *FileRepository) SetPhotoMeta(id int64, m PhotoMeta) error {
+	// EXIF 中的时区是没有名称的固定偏移，驱动写入后无法再读回，统一按 UTC 保存
+	var takenAt interface{}
+	if m.TakenAt != nil {
+		takenAt = m.TakenAt.UTC()
+	}
+	_, err := r.db.Exec("UPDATE files SET taken_at=?,camera=?,orientation=?,width=?,height=?,latitude=?,longitude=? WHERE id=?",
+		takenAt, m.Camera, m.Orientation, m.Width, m.Height, m.Latitude, m.Longitude, id)
+	return err
+}
+
+// GetByID 按 ID 查询未删除的文件，不存在时返回 nil, nil
+func (r *FileRepository) GetByID(id int64) (*File, error) {
+	f, err := scanFile(r.db.QueryRow("SELECT "+fileColumns+" FROM files WHERE id = ? AND trash_id = 0", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+// GetByPath 按逻辑存储路径查询未删除的文件，不存在时返回 nil, nil
+func (r *FileRepository) GetByPath(path string) (*File, error) {
+	f, err := scanFile(r.db.QueryRow("SELECT "+fileColumns+" FROM files WHERE path = ? AND trash_id = 0", path))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+// GetInFolder 按空间、目录和文件名查询，不存在时返回 nil, nil
+func (r *FileRepository) GetInFolder(spaceID, folderID int64, name string) (*File, error) {
+	f, err := scanFile(r.db.QueryRow("SELECT "+fileColumns+" FROM files WHERE space_id = ? AND folder_id = ? AND name = ? AND trash_id = 0", spaceID, folderID, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+// List 返回全部文件（含回收站中的），按上传时间排序
+func (r *FileRepository) List() ([]File, error) {
+	return r.query("SELECT " + fileColumns + " FROM files ORDER BY created_at, id")
+}
+
+// ListByFolder 分页列出某个空间某个目录下的文件，limit <= 0 表示不分页
+func (r *FileRepository) ListByFolder(spaceID, folderID int64, limit, offset int) ([]File, error) {
+	return r.ListTaggedInFolder(spaceID, folderID, nil, limit, offset)
+}
+
+// CountByFolder 统计某个空间某个目录下的文件数
+func (r *FileRepository) CountByFolder(spaceID, folderID int64) (int, error) {
+	return r.CountTaggedInFolder(spaceID, folderID, nil)
+}
+
+// 目录下未删除文件的查询条件，tagIDs 不为空时只取带有全部这些标签的文件
+func folderClause(spaceID, folderID int64, tagIDs []int64) (string, []interface{}) {
+	where := "space_id = ? AND folder_id = ? AND trash_id = 0"
+	args := []interface{}{spaceID, folderID}
+	if len(tagIDs) > 0 {
+		clause, tagArgs := taggedClause(tagIDs)
+		where += clause
+		args = append(args, tagArgs...)
+	}
+	return where, args
+}
+
+// ListTaggedInFolder 分页列出目录下带有全部 tagIDs 的文件，tagIDs 为空时不限，limit <= 0 表示不分页
+func (r *FileRepository) ListTaggedInFolder(spaceID, folderID int64, tagIDs []int64, limit, offset int) ([]File, error) {
+	where, args := folderClause(spaceID, folderID, tagIDs)
+	if limit <= 0 {
+		return r.query("SELECT "+fileColumns+" FROM files WHERE "+where+" ORDER BY name, id", args...)
+	}
+	return r.query("SELECT "+fileColumns+" FROM files WHERE "+where+" ORDER BY name, id LIMIT ? OFFSET ?", append(args, limit, offset)...)
+}
+
+// CountTaggedInFolder 统计目录下带有全部 tagIDs 的文件数，tagIDs 为空时不限
+func (r *FileRepository) CountTaggedInFolder(spaceID, folderID int64, tagIDs []int64) (int, error) {
+	where, args := folderClause(spaceID, folderID, tagIDs)
+	var n int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM files WHERE "+where, args...).Scan(&n)
+	return n, err
+}
+
+// ListPhotos 列出若干空间中未删除的图片
+func (r *FileRepository) ListPhotos(spaceIDs []int64) ([]File, error) {
+	if len(spaceIDs) == 0 {
+		return []File{}, nil
+	}
+	in, args := inPlaceholders(spaceIDs)
+	return r.query("SELECT "+fileColumns+" FROM files WHERE space_id IN ("+in+") AND trash_id = 0 AND content_type LIKE 'image/%' ORDER BY id", args...)
+}
+
+// FileFilter 搜索文件时交给数据库的过滤条件
+type FileFilter struct {
+	SpaceIDs []int64
+	OwnerID  int64   // 0 表示不限
+	MinSize  int64   // 0 表示不限
+	MaxSize  int64   // < 0 表示不限
+	TagIDs   []int64 // 带有全部这些标签
+	Favorite int64   // 非 0 时只取该用户收藏的文件
+}
+
+// Search 列出若干空间中符合条件的未删除文件，按更新时间倒序
+func (r *FileRepository) Search(f FileFilter) ([]File, error) {
+	if len(f.SpaceIDs) == 0 {
+		return []File{}, nil
+	}
+	in, args := inPlaceholders(f.SpaceIDs)
+	where := "space_id IN (" + in + ") AND trash_id = 0"
+	if f.OwnerID != 0 {
+		where += " AND owner_id = ?"
+		args = append(args, f.OwnerID)
+	}
+	if f.MinSize > 0 {
+		where += " AND size >= ?"
+		args = append(args, f.MinSize)
+	}
+	if f.MaxSize >= 0 {
+		where += " AND size <= ?"
+		args = append(args, f.MaxSize)
+	}
+	if len(f.TagIDs) > 0 {
+		clause, tagArgs := taggedClause(f.TagIDs)
+		where += clause
+		args = append(args, tagArgs...)
+	}
+	if f.Favorite != 0 {
+		where += " AND id IN (SELECT file_id FROM favorites WHERE user_id = ?)"
+		args = append(args, f.Favorite)
+	}
+	return r.query("SELECT "+fileColumns+" FROM files WHERE "+where+" ORDER BY updated_at DESC, id DESC", args...)
+}
+
+// ListUnscannedPhotos 按 ID 顺序列出 afterID 之后还没有解析过照片信息的文件，types 为要解析的内容类型
+func (r *FileRepository) ListUnscannedPhotos(types []string, afterID int64, limit int) ([]File, error) {
+	in, args := inPlaceholders(types)
+	args = append(args, afterID, limit)
+	return r.query("SELECT "+fileColumns+" FROM files WHERE orientation = 0 AND blob <> '' AND content_type IN ("+in+") AND id > ? ORDER BY id LIMIT ?", args...)
+}
+
+// ListByTrash 列出属于某个回收站条目的文件
+func (r *FileRepository) ListByTrash(trashID int64) ([]File, error) {
+	return r.query("SELECT "+fileColumns+" FROM files WHERE trash_id = ? ORDER BY id", trashID)
+}
+
+func (r *FileRepository) query(q string, args ...interface{}) ([]File, error) {
+	rows, err := r.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []File{}
+	for rows.Next() {
+		f, err := scanFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *f)
+	}
+	return list, rows.Err()
+}
+
+// Delete 删除记录
+func (r *FileRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM files WHERE id = ?", id)
+	return err
+}
@@ -0,0 +1,161 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Folder 目录记录，ParentID 为 0 表示位于根目录
+type Folder struct {
+	ID        int64     `json:"id"`
+	OwnerID   int64     `json:"owner_id"`
+	SpaceID   int64     `json:"space_id"`
+	ParentID  int64     `json:"parent_id"`
+	Name      string    `json:"name"`
+	TrashID   int64     `json:"trash_id"` // 非 0 表示已移入回收站
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const folderColumns = "id,owner_id,space_id,parent_id,name,trash_id,created_at,updated_at"
+
+// FolderRepository 负责 folders 表的读写
+type FolderRepository struct {
+	db *sql.DB
+}
+
+func NewFolderRepository(conn *sql.DB) *FolderRepository {
+	return &FolderRepository{db: conn}
+}
+
+func scanFolder(row rowScanner) (*Folder, error) {
+	var f Folder
+	err := row.Scan(&f.ID, &f.OwnerID, &f.SpaceID, &f.ParentID, &f.Name, &f.TrashID, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Create 插入新目录，并回填 ID
+func (r *FolderRepository) Create(f *Folder) error {
+	now := time.Now()
+	f.CreatedAt, f.UpdatedAt = now, now
+	res, err := r.db.Exec(
+		"INSERT INTO folders(owner_id,space_id,parent_id,name,created_at,updated_at) VALUES(?,?,?,?,?,?)",
+		f.OwnerID, f.SpaceID, f.ParentID, f.Name, f.CreatedAt, f.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	f.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetByID 按 ID 查询未删除的目录，不存在时返回 nil, nil
+func (r *FolderRepository) GetByID(id int64) (*Folder, error) {
+	f, err := scanFolder(r.db.QueryRow("SELECT "+folderColumns+" FROM folders WHERE id = ? AND trash_id = 0", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+// GetChild 按空间、父目录和名称查询，不存在时返回 nil, nil
+func (r *FolderRepository) GetChild(spaceID, parentID int64, name string) (*Folder, error) {
+	f, err := scanFolder(r.db.QueryRow("SELECT "+folderColumns+" FROM folders WHERE space_id = ? AND parent_id = ? AND name = ? AND trash_id = 0", spaceID, parentID, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return f, err
+}
+
+// ListChildren 分页列出子目录，limit <= 0 表示不分页
+func (r *FolderRepository) ListChildren(spaceID, parentID int64, limit, offset int) ([]Folder, error) {
+	if limit <= 0 {
+		return r.query("SELECT "+folderColumns+" FROM folders WHERE space_id = ? AND parent_id = ? AND trash_id = 0 ORDER BY name, id", spaceID, parentID)
+	}
+	return r.query("SELECT "+folderColumns+" FROM folders WHERE space_id = ? AND parent_id = ? AND trash_id = 0 ORDER BY name, id LIMIT ? OFFSET ?", spaceID, parentID, limit, offset)
+}
+
+// CountChildren 统计子目录数
+func (r *FolderRepository) CountChildren(spaceID, parentID int64) (int, error) {
+	var n int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM folders WHERE space_id = ? AND parent_id = ? AND trash_id = 0", spaceID, parentID).Scan(&n)
+	return n, err
+}
+
+// Subtree 返回以 id 为根、未删除的整棵子树（含自身），父目录在前
+func (r *FolderRepository) Subtree(id int64) ([]Folder, error) {
+	return r.query(`
+WITH RECURSIVE tree(id, depth) AS (
+    SELECT id, 0 FROM folders WHERE id = ? AND trash_id = 0
+    UNION ALL
+    SELECT f.id, tree.depth + 1 FROM folders f JOIN tree ON f.parent_id = tree.id WHERE f.trash_id = 0
+)
+SELECT `+prefixColumns("f", folderColumns)+` FROM folders f JOIN tree ON f.id = tree.id ORDER BY tree.depth, f.id`, id)
+}
+
+// Ancestors 返回从根到 id 的路径（含自身）
+func (r *FolderRepository) Ancestors(id int64) ([]Folder, error) {
+	var chain []Folder
+	for id != 0 {
+		f, err := r.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			break
+		}
+		chain = append([]Folder{*f}, chain...)
+		id = f.ParentID
+	}
+	return chain, nil
+}
+
+// Rename 重命名
+func (r *FolderRepository) Rename(id int64, name string) error {
+	_, err := r.db.Exec("UPDATE folders SET name = ?, updated_at = ? WHERE id = ?", name, time.Now(), id)
+	return err
+}
+
+// Move 移动到新的父目录
+func (r *FolderRepository) Move(id, parentID int64) error {
+	_, err := r.db.Exec("UPDATE folders SET parent_id = ?, updated_at = ? WHERE id = ?", parentID, time.Now(), id)
+	return err
+}
+
+// SetTrash 设置目录的回收站条目，0 表示恢复
+func (r *FolderRepository) SetTrash(id, trashID int64) error {
+	_, err := r.db.Exec("UPDATE folders SET trash_id = ?, updated_at = ? WHERE id = ?", trashID, time.Now(), id)
+	return err
+}
+
+// ListByTrash 列出属于某个回收站条目的目录
+func (r *FolderRepository) ListByTrash(trashID int64) ([]Folder, error) {
+	return r.query("SELECT "+folderColumns+" FROM folders WHERE trash_id = ? ORDER BY id", trashID)
+}
+
+// Delete 删除单个目录记录（不处理子项）
+func (r *FolderRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM folders WHERE id = ?", id)
+	return err
+}
+
+func (r *FolderRepository) query(q string, args ...interface{}) ([]Folder, error) {
+	rows, err := r.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Folder{}
+	for rows.Next() {
+		f, err := scanFolder(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *f)
+	}
+	return list, rows.Err()
+}
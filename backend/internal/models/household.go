@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Household 一个家庭。账号、家庭空间、家庭聊天和家庭配额都按家庭隔离
+type Household struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy int64     `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const householdColumns = "id,name,created_by,created_at"
+
+// HouseholdRepository 负责 households 表的读写，只使用两种数据库通用的 SQL
+type HouseholdRepository struct {
+	db *sql.DB
+}
+
+func NewHouseholdRepository(conn *sql.DB) *HouseholdRepository {
+	return &HouseholdRepository{db: conn}
+}
+
+func scanHousehold(row rowScanner) (*Household, error) {
+	var h Household
+	if err := row.Scan(&h.ID, &h.Name, &h.CreatedBy, &h.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// Create 插入新家庭，并回填 ID 和时间
+func (r *HouseholdRepository) Create(h *Household) error {
+	h.CreatedAt = time.Now().UTC()
+	res, err := r.db.Exec("INSERT INTO households(name,created_by,created_at) VALUES(?,?,?)", h.Name, h.CreatedBy, h.CreatedAt)
+	if err != nil {
+		return err
+	}
+	h.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetByID 按 ID 查询，不存在时返回 nil, nil
+func (r *HouseholdRepository) GetByID(id int64) (*Household, error) {
+	h, err := scanHousehold(r.db.QueryRow("SELECT "+householdColumns+" FROM households WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return h, err
+}
+
+// First 最早创建的家庭，即默认家庭，没有家庭时返回 nil, nil
+func (r *HouseholdRepository) First() (*Household, error) {
+	h, err := scanHousehold(r.db.QueryRow("SELECT " + householdColumns + " FROM households ORDER BY id LIMIT 1"))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return h, err
+}
+
+// Rename 修改家庭名称
+func (r *HouseholdRepository) Rename(id int64, name string) error {
+	_, err := r.db.Exec("UPDATE households SET name = ? WHERE id = ?", name, id)
+	return err
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Invitation 加入家庭的邀请码。库中只保存邀请码的 sha256，原文只在创建时返回。
+// MaxUses 为 1 时是一次性邀请，为 0 时在过期前不限次数
+type Invitation struct {
+	ID          int64      `json:"id"`
+	Code        string     `json:"-"`
+	HouseholdID int64      `json:"household_id"`
+	Role        string     `json:"role"`
+	CreatedBy   int64      `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	MaxUses     int        `json:"max_uses"`
+	Uses        int        `json:"uses"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+const invitationColumns = "id,code,household_id,role,created_by,created_at,expires_at,max_uses,uses,revoked_at"
+
+// Usable 邀请在 now 时是否仍可使用
+func (i *Invitation) Usable(now time.Time) bool {
+	return i.RevokedAt == nil && now.Before(i.ExpiresAt) && (i.MaxUses == 0 || i.Uses < i.MaxUses)
+}
+
+// InvitationRepository 负责 invitations 表的读写，只使用两种数据库通用的 SQL
+type InvitationRepository struct {
+	db *sql.DB
+}
+
+func NewInvitationRepository(conn *sql.DB) *InvitationRepository {
+	return &InvitationRepository{db: conn}
+}
+
+func scanInvitation(row rowScanner) (*Invitation, error) {
+	var (
+		i         Invitation
+		revokedAt sql.NullTime
+	)
+	if err := row.Scan(&i.ID, &i.Code, &i.HouseholdID, &i.Role, &i.CreatedBy, &i.CreatedAt, &i.ExpiresAt, &i.MaxUses, &i.Uses, &revokedAt); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		i.RevokedAt = &revokedAt.Time
+	}
+	return &i, nil
+}
+
+// Create 保存邀请，并回填 ID 和时间
+func (r *InvitationRepository) Create(i *Invitation) error {
+	i.CreatedAt = time.Now().UTC()
+	i.ExpiresAt = i.ExpiresAt.UTC()
+	res, err := r.db.Exec("INSERT INTO invitations(code,household_id,role,created_by,created_at,expires_at,max_uses,uses) VALUES(?,?,?,?,?,?,?,0)",
+		i.Code, i.HouseholdID, i.Role, i.CreatedBy, i.CreatedAt, i.ExpiresAt, i.MaxUses)
+	if err != nil {
+		return err
+	}
+	i.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetByCode 按邀请码（sha256）查询，不存在时返回 nil, nil
+func (r *InvitationRepository) GetByCode(code string) (*Invitation, error) {
+	i, err := scanInvitation(r.db.QueryRow("SELECT "+invitationColumns+" FROM invitations WHERE code = ?", code))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return i, err
+}
+
+// ListByHousehold 列出家庭的全部邀请，最新的在前
+func (r *InvitationRepository) ListByHousehold(householdID int64) ([]Invitation, error) {
+	rows, err := r.db.Query("SELECT "+invitationColumns+" FROM invitations WHERE household_id = ? ORDER BY id DESC", householdID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Invitation{}
+	for rows.Next() {
+		i, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *i)
+	}
+	return list, rows.Err()
+}
+
+// Redeem 凭邀请创建账号：在同一事务中使用一次邀请并插入 u，插入失败时邀请不计使用。
+// 条件更新保证并发注册不会超过次数，返回 false 表示邀请已不可用
+func (r *InvitationRepository) Redeem(id int64, u *User, now time.Time) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE invitations SET uses = uses + 1 WHERE id = ? AND revoked_at IS NULL AND expires_at > ? AND (max_uses = 0 OR uses < max_uses)",
+		id, now.UTC())
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return false, err
+	}
+	if err := insertUser(tx, u); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// Revoke 作废家庭的一个邀请，返回 false 表示邀请不存在或已作废
+func (r *InvitationRepository) Revoke(householdID, id int64, now time.Time) (bool, error) {
+	res, err := r.db.Exec("UPDATE invitations SET revoked_at = ? WHERE id = ? AND household_id = ? AND revoked_at IS NULL",
+		now.UTC(), id, householdID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// MetadataRepository 负责 file_metadata 表的读写：文件上自由填写的键值对，
+// 如“票据号: 2024-031”，对能看到文件的成员都可见
+type MetadataRepository struct {
+	db *sql.DB
+}
+
+func NewMetadataRepository(conn *sql.DB) *MetadataRepository {
+	return &MetadataRepository{db: conn}
+}
+
+// Get 返回文件的全部键值
+func (r *MetadataRepository) Get(fileID int64) (map[string]string, error) {
+	all, err := r.ForFiles([]int64{fileID})
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := all[fileID]; ok {
+		return m, nil
+	}
+	return map[string]string{}, nil
+}
+
+// ForFiles 返回各文件的键值，没有键值的文件不在结果中
+func (r *MetadataRepository) ForFiles(fileIDs []int64) (map[int64]map[string]string, error) {
+	metadata := map[int64]map[string]string{}
+	if len(fileIDs) == 0 {
+		return metadata, nil
+	}
+	in, args := inPlaceholders(fileIDs)
+	rows, err := r.db.Query("SELECT file_id, key, value FROM file_metadata WHERE file_id IN ("+in+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			fileID     int64
+			key, value string
+		)
+		if err := rows.Scan(&fileID, &key, &value); err != nil {
+			return nil, err
+		}
+		if metadata[fileID] == nil {
+			metadata[fileID] = map[string]string{}
+		}
+		metadata[fileID][key] = value
+	}
+	return metadata, rows.Err()
+}
+
+// Update 合并修改文件的键值，值为 nil 的键被删除
+func (r *MetadataRepository) Update(fileID int64, values map[string]*string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for key, value := range values {
+		if value == nil {
+			_, err = tx.Exec("DELETE FROM file_metadata WHERE file_id = ? AND key = ?", fileID, key)
+		} else {
+			_, err = tx.Exec("INSERT INTO file_metadata(file_id,key,value,updated_at) VALUES(?,?,?,?) "+
+				"ON CONFLICT(file_id, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at", fileID, key, *value, now)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// RemoveFileEverywhere 文件被彻底删除时清除它的键值
+func (r *MetadataRepository) RemoveFileEverywhere(fileID int64) error {
+	_, err := r.db.Exec("DELETE FROM file_metadata WHERE file_id = ?", fileID)
+	return err
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 预览图的生成状态
+const (
+	PreviewPending = "pending"
+	PreviewReady   = "ready"
+	PreviewFailed  = "failed"
+)
+
+// PreviewTypes 可以生成预览图的内容类型
+var PreviewTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// Preview blob 的预览图（缩略图）记录，同一内容只生成一次。
+// Format 为缩略图的编码（jpeg 或 png），Width、Height 为原图尺寸
+type Preview struct {
+	Blob      string    `json:"blob"`
+	Status    string    `json:"status"`
+	Format    string    `json:"format"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const previewColumns = "blob,status,format,width,height,created_at,updated_at"
+
+// PreviewRepository 负责 previews 表的读写
+type PreviewRepository struct {
+	db *sql.DB
+}
+
+func NewPreviewRepository(conn *sql.DB) *PreviewRepository {
+	return &PreviewRepository{db: conn}
+}
+
+func scanPreview(row rowScanner) (*Preview, error) {
+	var p Preview
+	if err := row.Scan(&p.Blob, &p.Status, &p.Format, &p.Width, &p.Height, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Get 按 blob 查询，不存在时返回 nil, nil
+func (r *PreviewRepository) Get(blob string) (*Preview, error) {
+	p, err := scanPreview(r.db.QueryRow("SELECT "+previewColumns+" FROM previews WHERE blob = ?", blob))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// Enqueue 登记待生成的预览图，已有记录时不变；返回是否新登记
+func (r *PreviewRepository) Enqueue(blob string) (bool, error) {
+	now := time.Now()
+	res, err := r.db.Exec("INSERT OR IGNORE INTO previews(blob,status,created_at,updated_at) VALUES(?,?,?,?)", blob, PreviewPending, now, now)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Backfill 为还没有预览记录的图片文件补登记，返回登记条数
+func (r *PreviewRepository) Backfill() (int, error) {
+	now := time.Now()
+	in, typeArgs := inPlaceholders(PreviewTypes)
+	args := append([]interface{}{PreviewPending, now, now}, typeArgs...)
+	res, err := r.db.Exec(`
+INSERT OR IGNORE INTO previews(blob,status,created_at,updated_at)
+SELECT DISTINCT blob, ?, ?, ? FROM files WHERE blob <> '' AND content_type IN (`+in+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// ListPending 按登记顺序列出待生成的预览
+func (r *PreviewRepository) ListPending(limit int) ([]Preview, error) {
+	rows, err := r.db.Query("SELECT "+previewColumns+" FROM previews WHERE status = ? ORDER BY created_at, blob LIMIT ?", PreviewPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Preview{}
+	for rows.Next() {
+		p, err := scanPreview(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *p)
+	}
+	return list, rows.Err()
+}
+
+// MarkReady 记录预览已生成
+func (r *PreviewRepository) MarkReady(blob, format string, width, height int) error {
+	_, err := r.db.Exec("UPDATE previews SET status = ?, format = ?, width = ?, height = ?, updated_at = ? WHERE blob = ?",
+		PreviewReady, format, width, height, time.Now(), blob)
+	return err
+}
+
+// MarkFailed 记录无法生成预览（格式不支持、文件损坏等），之后不再重试
+func (r *PreviewRepository) MarkFailed(blob string) error {
+	_, err := r.db.Exec("UPDATE previews SET status = ?, updated_at = ? WHERE blob = ?", PreviewFailed, time.Now(), blob)
+	return err
+}
+
+// Ready 返回 blobs 中已生成预览的集合
+func (r *PreviewRepository) Ready(blobs []string) (map[string]bool, error) {
+	ready := map[string]bool{}
+	if len(blobs) == 0 {
+		return ready, nil
+	}
+	in, blobArgs := inPlaceholders(blobs)
+	args := append([]interface{}{PreviewReady}, blobArgs...)
+	rows, err := r.db.Query("SELECT blob FROM previews WHERE status = ? AND blob IN ("+in+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var b string
+		if err := rows.Scan(&b); err != nil {
+			return nil, err
+		}
+		ready[b] = true
+	}
+	return ready, rows.Err()
+}
+
+// Delete 删除记录
+func (r *PreviewRepository) Delete(blob string) error {
+	_, err := r.db.Exec("DELETE FROM previews WHERE blob = ?", blob)
+	return err
+}
+
+// Requeue 让已生成的预览重新生成（例如补读到照片方向之后）
+func (r *PreviewRepository) Requeue(blob string) error {
+	_, err := r.db.Exec("UPDATE previews SET status = ?, updated_at = ? WHERE blob = ? AND status = ?", PreviewPending, time.Now(), blob, PreviewReady)
+	return err
+}
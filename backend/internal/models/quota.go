@@ -0,0 +1,142 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 配额范围
+const (
+	QuotaUser   = "user"
+	QuotaFamily = "family"
+)
+
+// Quota 存储配额：SubjectID 为用户 ID 或家庭 ID，LimitBytes 为 0 表示不限
+type Quota struct {
+	Scope      string    `json:"scope"`
+	SubjectID  int64     `json:"subject_id"`
+	LimitBytes int64     `json:"limit_bytes"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Usage 存储用量（字节）：Files 为未删除的文件，Trash 为回收站中的文件，
+// Versions 为历史版本，Pending 为进行中的断点续传预占的空间
+type Usage struct {
+	Files    int64 `json:"files"`
+	Trash    int64 `json:"trash"`
+	Versions int64 `json:"versions"`
+	Pending  int64 `json:"pending"`
+}
+
+// Used 已占用的空间，不含预占
+func (u *Usage) Used() int64 {
+	return u.Files + u.Trash + u.Versions
+}
+
+// QuotaRepository 负责 quotas 表的读写和用量统计
+type QuotaRepository struct {
+	db *sql.DB
+}
+
+func NewQuotaRepository(conn *sql.DB) *QuotaRepository {
+	return &QuotaRepository{db: conn}
+}
+
+// Get 查询配额，未设置时返回 nil, nil
+func (r *QuotaRepository) Get(scope string, subjectID int64) (*Quota, error) {
+	var q Quota
+	err := r.db.QueryRow("SELECT scope,subject_id,limit_bytes,updated_at FROM quotas WHERE scope = ? AND subject_id = ?", scope, subjectID).
+		Scan(&q.Scope, &q.SubjectID, &q.LimitBytes, &q.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// Set 设置配额，已存在时覆盖
+func (r *QuotaRepository) Set(q *Quota) error {
+	q.UpdatedAt = time.Now()
+	_, err := r.db.Exec(
+		"INSERT INTO quotas(scope,subject_id,limit_bytes,updated_at) VALUES(?,?,?,?) ON CONFLICT(scope, subject_id) DO UPDATE SET limit_bytes = excluded.limit_bytes, updated_at = excluded.updated_at",
+		q.Scope, q.SubjectID, q.LimitBytes, q.UpdatedAt,
+	)
+	return err
+}
+
+// Delete 删除配额，恢复为默认值
+func (r *QuotaRepository) Delete(scope string, subjectID int64) error {
+	_, err := r.db.Exec("DELETE FROM quotas WHERE scope = ? AND subject_id = ?", scope, subjectID)
+	return err
+}
+
+// List 列出全部单独设置的配额
+func (r *QuotaRepository) List() ([]Quota, error) {
+	rows, err := r.db.Query("SELECT scope,subject_id,limit_bytes,updated_at FROM quotas ORDER BY scope, subject_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Quota{}
+	for rows.Next() {
+		var q Quota
+		if err := rows.Scan(&q.Scope, &q.SubjectID, &q.LimitBytes, &q.UpdatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, q)
+	}
+	return list, rows.Err()
+}
+
+// AdoptFamily 把引入家庭之前的全家配额（SubjectID 为 0）归给 householdID，
+// 该家庭已有配额时不做处理
+func (r *QuotaRepository) AdoptFamily(householdID int64) error {
+	_, err := r.db.Exec("UPDATE quotas SET subject_id = ? WHERE scope = ? AND subject_id = 0 AND NOT EXISTS (SELECT 1 FROM quotas WHERE scope = ? AND subject_id = ?)",
+		householdID, QuotaFamily, QuotaFamily, householdID)
+	return err
+}
+
+// UserUsage 统计计入 userID 名下的用量：文件按所有者计，历史版本按上传者计
+func (r *QuotaRepository) UserUsage(userID int64) (*Usage, error) {
+	var u Usage
+	err := r.db.QueryRow(`
+SELECT
+    COALESCE((SELECT SUM(size) FROM files WHERE owner_id = ? AND trash_id = 0), 0),
+    COALESCE((SELECT SUM(size) FROM files WHERE owner_id = ? AND trash_id <> 0), 0),
+    COALESCE((SELECT SUM(size) FROM file_versions WHERE uploader_id = ? AND path <> ''), 0),
+    COALESCE((SELECT SUM(length) FROM tus_uploads WHERE owner_id = ?), 0)`,
+		userID, userID, userID, userID,
+	).Scan(&u.Files, &u.Trash, &u.Versions, &u.Pending)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// HouseholdUsage 统计一个家庭的用量：计入 ownerIDs（家庭成员）名下的用量之和
+func (r *QuotaRepository) HouseholdUsage(ownerIDs []int64) (*Usage, error) {
+	var u Usage
+	if len(ownerIDs) == 0 {
+		return &u, nil
+	}
+	in, ids := inPlaceholders(ownerIDs)
+	args := make([]interface{}, 0, 4*len(ids))
+	for i := 0; i < 4; i++ {
+		args = append(args, ids...)
+	}
+	err := r.db.QueryRow(`
+SELECT
+    COALESCE((SELECT SUM(size) FROM files WHERE owner_id IN (`+in+`) AND trash_id = 0), 0),
+    COALESCE((SELECT SUM(size) FROM files WHERE owner_id IN (`+in+`) AND trash_id <> 0), 0),
+    COALESCE((SELECT SUM(size) FROM file_versions WHERE uploader_id IN (`+in+`) AND path <> ''), 0),
+    COALESCE((SELECT SUM(length) FROM tus_uploads WHERE owner_id IN (`+in+`)), 0)`,
+		args...,
+	).Scan(&u.Files, &u.Trash, &u.Versions, &u.Pending)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RefreshToken 刷新令牌。库中只保存令牌的 sha256，原文只在签发时返回给客户端。
+// 每次刷新都会作废旧令牌并签发同一 Family 中的新令牌，一次登录对应一个 Family
+type RefreshToken struct {
+	Token     string     `json:"-"`
+	UserID    int64      `json:"user_id"`
+	Family    string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// RefreshTokenRepository 负责 refresh_tokens 表的读写，只使用两种数据库通用的 SQL
+type RefreshTokenRepository struct {
+	db *sql.DB
+}
+
+func NewRefreshTokenRepository(conn *sql.DB) *RefreshTokenRepository {
+	return &RefreshTokenRepository{db: conn}
+}
+
+// Create 保存令牌
+func (r *RefreshTokenRepository) Create(t *RefreshToken) error {
+	t.CreatedAt = time.Now().UTC()
+	_, err := r.db.Exec("INSERT INTO refresh_tokens(token,user_id,family,created_at,expires_at) VALUES(?,?,?,?,?)",
+		t.Token, t.UserID, t.Family, t.CreatedAt, t.ExpiresAt.UTC())
+	return err
+}
+
+// Get 按令牌查询，已作废的也会返回，不存在时返回 nil, nil
+func (r *RefreshTokenRepository) Get(token string) (*RefreshToken, error) {
+	var (
+		t                    = RefreshToken{Token: token}
+		createdAt, revokedAt sql.NullTime
+	)
+	err := r.db.QueryRow("SELECT user_id,family,created_at,expires_at,revoked_at FROM refresh_tokens WHERE token = ?", token).
+		Scan(&t.UserID, &t.Family, &createdAt, &t.ExpiresAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.CreatedAt = createdAt.Time
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	return &t, nil
+}
+
+// Revoke 作废一个有效令牌。返回 false 表示令牌已被作废（例如被并发的刷新抢先使用）
+func (r *RefreshTokenRepository) Revoke(token string, now time.Time) (bool, error) {
+	res, err := r.db.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE token = ? AND revoked_at IS NULL", now.UTC(), token)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RevokeFamily 作废同一 Family 中仍有效的全部令牌，返回作废的条数
+func (r *RefreshTokenRepository) RevokeFamily(family string, now time.Time) (int64, error) {
+	res, err := r.db.Exec("UPDATE refresh_tokens SET revoked_at = ? WHERE family = ? AND revoked_at IS NULL", now.UTC(), family)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteExpired 删除过期令牌，返回删除的条数。作废的令牌保留到过期，用于识别重复使用
+func (r *RefreshTokenRepository) DeleteExpired(now time.Time) (int64, error) {
+	res, err := r.db.Exec("DELETE FROM refresh_tokens WHERE expires_at < ?", now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,122 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Session 一次登录（一台设备）。ID 同时是这次登录的刷新令牌 Family，
+// 访问令牌中带有 ID，会话被作废或删除后令牌随即失效
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     int64      `json:"user_id"`
+	DeviceName string     `json:"device_name"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+const sessionColumns = "id,user_id,device_name,ip,user_agent,created_at,last_seen_at,expires_at,revoked_at"
+
+// SessionRepository 负责 sessions 表的读写，只使用两种数据库通用的 SQL
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(conn *sql.DB) *SessionRepository {
+	return &SessionRepository{db: conn}
+}
+
+func scanSession(row rowScanner) (*Session, error) {
+	var (
+		s         Session
+		revokedAt sql.NullTime
+	)
+	if err := row.Scan(&s.ID, &s.UserID, &s.DeviceName, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		s.RevokedAt = &revokedAt.Time
+	}
+	return &s, nil
+}
+
+// Active 会话在 now 时是否仍然有效
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// Create 保存新会话
+func (r *SessionRepository) Create(s *Session) error {
+	s.CreatedAt = time.Now().UTC()
+	s.LastSeenAt = s.CreatedAt
+	s.ExpiresAt = s.ExpiresAt.UTC()
+	_, err := r.db.Exec("INSERT INTO sessions("+sessionColumns+") VALUES(?,?,?,?,?,?,?,?,NULL)",
+		s.ID, s.UserID, s.DeviceName, s.IP, s.UserAgent, s.CreatedAt, s.LastSeenAt, s.ExpiresAt)
+	return err
+}
+
+// Get 按 ID 查询，已作废的也会返回，不存在时返回 nil, nil
+func (r *SessionRepository) Get(id string) (*Session, error) {
+	s, err := scanSession(r.db.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ListActive 列出用户仍然有效的会话，最近活动的在前
+func (r *SessionRepository) ListActive(userID int64, now time.Time) ([]Session, error) {
+	rows, err := r.db.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_seen_at DESC",
+		userID, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Session{}
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, rows.Err()
+}
+
+// Touch 记录会话的最近活动时间和 IP，距上次记录不足 interval 时不写库
+func (r *SessionRepository) Touch(id, ip string, now time.Time, interval time.Duration) error {
+	now = now.UTC()
+	_, err := r.db.Exec("UPDATE sessions SET last_seen_at = ?, ip = ? WHERE id = ? AND (last_seen_at < ? OR ip <> ?)",
+		now, ip, id, now.Add(-interval), ip)
+	return err
+}
+
+// Extend 刷新令牌轮换后，把会话的过期时间延到新令牌的过期时间
+func (r *SessionRepository) Extend(id string, expiresAt time.Time) error {
+	_, err := r.db.Exec("UPDATE sessions SET expires_at = ? WHERE id = ?", expiresAt.UTC(), id)
+	return err
+}
+
+// Revoke 作废会话，返回 false 表示会话不存在或已作废
+func (r *SessionRepository) Revoke(id string, now time.Time) (bool, error) {
+	res, err := r.db.Exec("UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL", now.UTC(), id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// DeleteExpired 删除过期的会话，返回删除的条数
+func (r *SessionRepository) DeleteExpired(now time.Time) (int64, error) {
+	res, err := r.db.Exec("DELETE FROM sessions WHERE expires_at < ?", now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Share 分享链接记录
+type Share struct {
+	Token        string    `json:"token"`
+	FileID       int64     `json:"file_id"`   // 0 表示旧数据，只能按文件名定位
+	FolderID     int64     `json:"folder_id"` // 非 0 表示分享整个目录
+	AlbumID      int64     `json:"album_id"`  // 非 0 表示分享相册
+	Filename     string    `json:"filename"`  // 创建时的文件名（目录名、相册名）快照
+	PasswordHash string    `json:"-"`         // bcrypt，空字符串表示无密码
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxAccess    int       `json:"max_access"` // 0 表示不限次数
+	AccessCount  int       `json:"access_count"`
+	CreatedBy    int64     `json:"created_by"`
+	IsActive     bool      `json:"is_active"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// IsFolder 是否为目录分享
+func (s *Share) IsFolder() bool {
+	return s.FolderID != 0
+}
+
+// IsAlbum 是否为相册分享
+func (s *Share) IsAlbum() bool {
+	return s.AlbumID != 0
+}
+
+// IsExpired 是否已过期
+func (s *Share) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// IsExhausted 访问次数是否已用完
+func (s *Share) IsExhausted() bool {
+	return s.MaxAccess > 0 && s.AccessCount >= s.MaxAccess
+}
+
+const shareColumns = "token,file_id,folder_id,album_id,filename,password_hash,expires_at,max_access,access_count,created_by,is_active,created_at"
+
+// ShareRepository 负责 share_links 表的读写
+type ShareRepository struct {
+	db *sql.DB
+}
+
+func NewShareRepository(conn *sql.DB) *ShareRepository {
+	return &ShareRepository{db: conn}
+}
+
+func scanShare(row rowScanner) (*Share, error) {
+	var s Share
+	err := row.Scan(&s.Token, &s.FileID, &s.FolderID, &s.AlbumID, &s.Filename, &s.PasswordHash, &s.ExpiresAt,
+		&s.MaxAccess, &s.AccessCount, &s.CreatedBy, &s.IsActive, &s.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Create 插入新的分享记录
+func (r *ShareRepository) Create(s *Share) error {
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+	s.IsActive = true
+	_, err := r.db.Exec(
+		"INSERT INTO share_links("+shareColumns+") VALUES(?,?,?,?,?,?,?,?,?,?,?,?)",
+		s.Token, s.FileID, s.FolderID, s.AlbumID, s.Filename, s.PasswordHash, s.ExpiresAt,
+		s.MaxAccess, s.AccessCount, s.CreatedBy, s.IsActive, s.CreatedAt,
+	)
+	return err
+}
+
+// GetByToken 按 token 查询，不存在时返回 nil, nil
+func (r *ShareRepository) GetByToken(token string) (*Share, error) {
+	s, err := scanShare(r.db.QueryRow("SELECT "+shareColumns+" FROM share_links WHERE token = ?", token))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// ConsumeAccess 原子地增加一次访问计数；
+// 链接失效或次数已用完时返回 false
+func (r *ShareRepository) ConsumeAccess(token string) (bool, error) {
+	res, err := r.db.Exec(
+		"UPDATE share_links SET access_count = access_count + 1 WHERE token = ? AND is_active = 1 AND (max_access = 0 OR access_count < max_access)",
+		token,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ListByCreator 列出某个用户创建的、未被撤销的分享
+func (r *ShareRepository) ListByCreator(userID int64) ([]Share, error) {
+	rows, err := r.db.Query("SELECT "+shareColumns+" FROM share_links WHERE created_by = ? AND is_active = 1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Share{}
+	for rows.Next() {
+		s, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *s)
+	}
+	return list, rows.Err()
+}
+
+// Update 修改有效期、次数上限和密码
+func (r *ShareRepository) Update(s *Share) error {
+	_, err := r.db.Exec(
+		"UPDATE share_links SET expires_at = ?, max_access = ?, password_hash = ? WHERE token = ?",
+		s.ExpiresAt, s.MaxAccess, s.PasswordHash, s.Token,
+	)
+	return err
+}
+
+// Revoke 撤销分享，记录保留但链接立即失效
+func (r *ShareRepository) Revoke(token string) error {
+	_, err := r.db.Exec("UPDATE share_links SET is_active = 0 WHERE token = ?", token)
+	return err
+}
+
+// DeleteByFile 删除某个文件的全部分享
+func (r *ShareRepository) DeleteByFile(fileID int64, filename string) error {
+	_, err := r.db.Exec("DELETE FROM share_links WHERE file_id = ? OR (file_id = 0 AND filename = ?)", fileID, filename)
+	return err
+}
+
+// DeleteByFolder 删除某个目录的全部分享
+func (r *ShareRepository) DeleteByFolder(folderID int64) error {
+	_, err := r.db.Exec("DELETE FROM share_links WHERE folder_id = ?", folderID)
+	return err
+}
+
+// DeleteByAlbum 删除某个相册的全部分享
+func (r *ShareRepository) DeleteByAlbum(albumID int64) error {
+	_, err := r.db.Exec("DELETE FROM share_links WHERE album_id = ?", albumID)
+	return err
+}
+
+// ImportLegacy 把旧版分享记录迁移到 share_links，返回导入条数和因 token 已被占用而放弃的旧链接。
+// 旧版有两套分享实现：handlers/share.go 写入 shares 表（明文密码），表在 SQLite 中，
+// 或在账号所在的 MySQL 中（mysql 为该连接，没有时传 nil）；cmd/server 的 shareRecords
+// 只存在内存里，进程重启即丢失，没有可迁移的数据。迁移后旧表改名为 shares_legacy，避免重复导入
+func (r *ShareRepository) ImportLegacy(mysql *sql.DB) (int, []string, error) {
+	imported, skipped, err := r.importLegacyTable(r.db, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'shares'")
+	if err != nil || mysql == nil {
+		return imported, skipped, err
+	}
+	n, more, err := r.importLegacyTable(mysql, "SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = DATABASE() AND table_name = 'shares'")
+	return imported + n, append(skipped, more...), err
+}
+
+// 导入 conn 中的旧 shares 表，exists 查询该表是否存在
+func (r *ShareRepository) importLegacyTable(conn *sql.DB, exists string) (int, []string, error) {
+	var n int
+	if err := conn.QueryRow(exists).Scan(&n); err != nil || n == 0 {
+		return 0, nil, err
+	}
+
+	rows, err := conn.Query("SELECT id, filename, password, expires_at, max_access, access_count, user_id FROM shares")
+	if err != nil {
+		return 0, nil, err
+	}
+	var legacy []Share
+	var passwords []string
+	for rows.Next() {
+		var s Share
+		var password string
+		if err := rows.Scan(&s.Token, &s.Filename, &password, &s.ExpiresAt, &s.MaxAccess, &s.AccessCount, &s.CreatedBy); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		legacy = append(legacy, s)
+		passwords = append(passwords, password)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	imported := 0
+	var skipped []string
+	for i := range legacy {
+		s := &legacy[i]
+		if existing, err := r.GetByToken(s.Token); err != nil {
+			return imported, skipped, err
+		} else if existing != nil {
+			// 两张旧表或新表中已有同一 token，保留先出现的那条
+			if existing.Filename != s.Filename {
+				skipped = append(skipped, s.Token)
+			}
+			continue
+		}
+		if passwords[i] != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(passwords[i]), bcrypt.DefaultCost)
+			if err != nil {
+				return imported, skipped, err
+			}
+			s.PasswordHash = string(hash)
+		}
+		if err := r.Create(s); err != nil {
+			return imported, skipped, err
+		}
+		imported++
+	}
+
+	_, err = conn.Exec("ALTER TABLE shares RENAME TO shares_legacy")
+	return imported, skipped, err
+}
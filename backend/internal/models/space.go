@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 空间类型
+const (
+	SpacePrivate = "private" // 个人私有空间，OwnerID 为用户 ID
+	SpaceFamily  = "family"  // 全家共享空间，OwnerID 为家庭 ID
+)
+
+// Space 文件空间，文件和目录都归属于某个空间
+type Space struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	OwnerID   int64     `json:"owner_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const spaceColumns = "id,kind,owner_id,created_at"
+
+// SpaceRepository 负责 spaces 表的读写
+type SpaceRepository struct {
+	db *sql.DB
+}
+
+func NewSpaceRepository(conn *sql.DB) *SpaceRepository {
+	return &SpaceRepository{db: conn}
+}
+
+func scanSpace(row rowScanner) (*Space, error) {
+	var s Space
+	if err := row.Scan(&s.ID, &s.Kind, &s.OwnerID, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// GetByID 按 ID 查询，不存在时返回 nil, nil
+func (r *SpaceRepository) GetByID(id int64) (*Space, error) {
+	s, err := scanSpace(r.db.QueryRow("SELECT "+spaceColumns+" FROM spaces WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return s, err
+}
+
+// Ensure 返回指定类型和所有者的空间，不存在时创建
+func (r *SpaceRepository) Ensure(kind string, ownerID int64) (*Space, error) {
+	const q = "SELECT " + spaceColumns + " FROM spaces WHERE kind = ? AND owner_id = ?"
+	s, err := scanSpace(r.db.QueryRow(q, kind, ownerID))
+	if err != sql.ErrNoRows {
+		return s, err
+	}
+	if _, err := r.db.Exec("INSERT OR IGNORE INTO spaces(kind, owner_id, created_at) VALUES(?,?,?)", kind, ownerID, time.Now()); err != nil {
+		return nil, err
+	}
+	return scanSpace(r.db.QueryRow(q, kind, ownerID))
+}
+
+// AdoptFamily 把引入家庭之前的家庭空间（OwnerID 为 0）归给 householdID，
+// 该家庭已有家庭空间时不做处理
+func (r *SpaceRepository) AdoptFamily(householdID int64) error {
+	_, err := r.db.Exec("UPDATE spaces SET owner_id = ? WHERE kind = ? AND owner_id = 0 AND NOT EXISTS (SELECT 1 FROM spaces WHERE kind = ? AND owner_id = ?)",
+		householdID, SpaceFamily, SpaceFamily, householdID)
+	return err
+}
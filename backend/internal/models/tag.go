@@ -0,0 +1,235 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Tag 用户自己的标签，如“2024 报税”“孩子学校”。标签只对创建者可见，
+// 同一个文件可以被不同成员打上各自的标签
+type Tag struct {
+	ID        int64     `json:"id"`
+	OwnerID   int64     `json:"owner_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// 查询时计算：打了这个标签的未删除文件数
+	Count int `json:"count"`
+}
+
+const tagColumns = "t.id,t.owner_id,t.name,t.color,t.created_at," +
+	"(SELECT COUNT(*) FROM file_tags ft JOIN files f ON f.id = ft.file_id AND f.trash_id = 0 WHERE ft.tag_id = t.id)"
+
+// TagRepository 负责 tags、file_tags 表的读写
+type TagRepository struct {
+	db *sql.DB
+}
+
+func NewTagRepository(conn *sql.DB) *TagRepository {
+	return &TagRepository{db: conn}
+}
+
+func scanTag(row rowScanner) (*Tag, error) {
+	var t Tag
+	if err := row.Scan(&t.ID, &t.OwnerID, &t.Name, &t.Color, &t.CreatedAt, &t.Count); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Create 插入新标签，并回填 ID
+func (r *TagRepository) Create(t *Tag) error {
+	t.CreatedAt = time.Now()
+	res, err := r.db.Exec("INSERT INTO tags(owner_id,name,color,created_at) VALUES(?,?,?,?)", t.OwnerID, t.Name, t.Color, t.CreatedAt)
+	if err != nil {
+		return err
+	}
+	t.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetByID 按 ID 查询，不存在时返回 nil, nil
+func (r *TagRepository) GetByID(id int64) (*Tag, error) {
+	t, err := scanTag(r.db.QueryRow("SELECT "+tagColumns+" FROM tags t WHERE t.id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// GetByName 按名称查询用户的标签（不区分大小写），不存在时返回 nil, nil
+func (r *TagRepository) GetByName(ownerID int64, name string) (*Tag, error) {
+	t, err := scanTag(r.db.QueryRow("SELECT "+tagColumns+" FROM tags t WHERE t.owner_id = ? AND t.name = ? COLLATE NOCASE", ownerID, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// ListByOwner 按名称列出用户的全部标签
+func (r *TagRepository) ListByOwner(ownerID int64) ([]Tag, error) {
+	rows, err := r.db.Query("SELECT "+tagColumns+" FROM tags t WHERE t.owner_id = ? ORDER BY t.name COLLATE NOCASE, t.id", ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []Tag{}
+	for rows.Next() {
+		t, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, rows.Err()
+}
+
+// Update 修改名称和颜色
+func (r *TagRepository) Update(t *Tag) error {
+	_, err := r.db.Exec("UPDATE tags SET name = ?, color = ? WHERE id = ?", t.Name, t.Color, t.ID)
+	return err
+}
+
+// Delete 删除标签，文件上的这个标签随之去掉
+func (r *TagRepository) Delete(id int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM file_tags WHERE tag_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM tags WHERE id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Ensure 按名称取用户的标签，不存在的自动创建，返回顺序与 names 一致
+func (r *TagRepository) Ensure(ownerID int64, names []string) ([]Tag, error) {
+	tags := make([]Tag, 0, len(names))
+	for _, name := range names {
+		t, err := r.GetByName(ownerID, name)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			t = &Tag{OwnerID: ownerID, Name: name}
+			if err := r.Create(t); err != nil {
+				return nil, err
+			}
+		}
+		tags = append(tags, *t)
+	}
+	return tags, nil
+}
+
+// Lookup 按名称查找用户已有的标签 ID，返回的 ok 表示是否全部存在
+func (r *TagRepository) Lookup(ownerID int64, names []string) (ids []int64, ok bool, err error) {
+	for _, name := range names {
+		var id int64
+		err := r.db.QueryRow("SELECT id FROM tags WHERE owner_id = ? AND name = ? COLLATE NOCASE", ownerID, name).Scan(&id)
+		if err == sql.ErrNoRows {
+			return nil, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, true, nil
+}
+
+// Tag 给文件打上标签，已有的不变
+func (r *TagRepository) Tag(tagIDs, fileIDs []int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, tagID := range tagIDs {
+		for _, fileID := range fileIDs {
+			if _, err := tx.Exec("INSERT OR IGNORE INTO file_tags(file_id,tag_id,created_at) VALUES(?,?,?)", fileID, tagID, now); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Untag 去掉文件上的标签
+func (r *TagRepository) Untag(tagIDs, fileIDs []int64) error {
+	if len(tagIDs) == 0 || len(fileIDs) == 0 {
+		return nil
+	}
+	tagIn, args := inPlaceholders(tagIDs)
+	fileIn, fileArgs := inPlaceholders(fileIDs)
+	_, err := r.db.Exec("DELETE FROM file_tags WHERE tag_id IN ("+tagIn+") AND file_id IN ("+fileIn+")", append(args, fileArgs...)...)
+	return err
+}
+
+// SetFileTags 把用户在文件上的标签替换为 tagIDs，其他成员的标签不受影响
+func (r *TagRepository) SetFileTags(ownerID, fileID int64, tagIDs []int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM file_tags WHERE file_id = ? AND tag_id IN (SELECT id FROM tags WHERE owner_id = ?)", fileID, ownerID); err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, tagID := range tagIDs {
+		if _, err := tx.Exec("INSERT OR IGNORE INTO file_tags(file_id,tag_id,created_at) VALUES(?,?,?)", fileID, tagID, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ForFiles 返回用户在各文件上的标签名，按名称排序
+func (r *TagRepository) ForFiles(ownerID int64, fileIDs []int64) (map[int64][]string, error) {
+	tags := map[int64][]string{}
+	if len(fileIDs) == 0 {
+		return tags, nil
+	}
+	in, args := inPlaceholders(fileIDs)
+	rows, err := r.db.Query(`
+SELECT ft.file_id, t.name FROM file_tags ft JOIN tags t ON t.id = ft.tag_id
+WHERE t.owner_id = ? AND ft.file_id IN (`+in+`) ORDER BY t.name COLLATE NOCASE`, append([]interface{}{ownerID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			fileID int64
+			name   string
+		)
+		if err := rows.Scan(&fileID, &name); err != nil {
+			return nil, err
+		}
+		tags[fileID] = append(tags[fileID], name)
+	}
+	return tags, rows.Err()
+}
+
+// RemoveFileEverywhere 文件被彻底删除时去掉它的全部标签
+func (r *TagRepository) RemoveFileEverywhere(fileID int64) error {
+	_, err := r.db.Exec("DELETE FROM file_tags WHERE file_id = ?", fileID)
+	return err
+}
+
+// taggedClause 生成“文件带有全部 tagIDs”的条件，用于 files 表的查询
+func taggedClause(tagIDs []int64) (string, []interface{}) {
+	in, args := inPlaceholders(tagIDs)
+	return " AND id IN (SELECT file_id FROM file_tags WHERE tag_id IN (" + in + ") GROUP BY file_id HAVING COUNT(*) = ?)",
+		append(args, len(tagIDs))
+}
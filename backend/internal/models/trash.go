@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// 回收站条目类型
+const (
+	TrashFile   = "file"
+	TrashFolder = "folder"
+)
+
+// TrashItem 回收站条目：一次删除操作对应一条，
+// 被删除的文件和目录（含整棵子树）通过 trash_id 指向它
+type TrashItem struct {
+	ID        int64     `json:"id"`
+	OwnerID   int64     `json:"owner_id"` // 执行删除的用户，回收站按人划分
+	SpaceID   int64     `json:"space_id"`
+	FolderID  int64     `json:"folder_id"` // 删除前所在目录
+	Kind      string    `json:"kind"`
+	ItemID    int64     `json:"item_id"`
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+const trashColumns = "id,owner_id,space_id,folder_id,kind,item_id,name,size,deleted_at"
+
+// TrashRepository 负责 trash 表的读写
+type TrashRepository struct {
+	db *sql.DB
+}
+
+func NewTrashRepository(conn *sql.DB) *TrashRepository {
+	return &TrashRepository{db: conn}
+}
+
+func scanTrash(row rowScanner) (*TrashItem, error) {
+	var t TrashItem
+	err := row.Scan(&t.ID, &t.OwnerID, &t.SpaceID, &t.FolderID, &t.Kind, &t.ItemID, &t.Name, &t.Size, &t.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Create 插入新条目，并回填 ID
+func (r *TrashRepository) Create(t *TrashItem) error {
+	t.DeletedAt = time.Now()
+	res, err := r.db.Exec(
+		"INSERT INTO trash(owner_id,space_id,folder_id,kind,item_id,name,size,deleted_at) VALUES(?,?,?,?,?,?,?,?)",
+		t.OwnerID, t.SpaceID, t.FolderID, t.Kind, t.ItemID, t.Name, t.Size, t.DeletedAt,
+	)
+	if err != nil {
+		return err
+	}
+	t.ID, err = res.LastInsertId()
+	return err
+}
+
+// UpdateSize 更新条目占用的空间
+func (r *TrashRepository) UpdateSize(id, size int64) error {
+	_, err := r.db.Exec("UPDATE trash SET size = ? WHERE id = ?", size, id)
+	return err
+}
+
+// GetByID 按 ID 查询，不存在时返回 nil, nil
+func (r *TrashRepository) GetByID(id int64) (*TrashItem, error) {
+	t, err := scanTrash(r.db.QueryRow("SELECT "+trashColumns+" FROM trash WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// ListByOwner 列出某个用户的回收站，最近删除的在前
+func (r *TrashRepository) ListByOwner(ownerID int64) ([]TrashItem, error) {
+	return r.query("SELECT "+trashColumns+" FROM trash WHERE owner_id = ? ORDER BY deleted_at DESC, id DESC", ownerID)
+}
+
+// ListBefore 列出 before 之前删除的条目
+func (r *TrashRepository) ListBefore(before time.Time) ([]TrashItem, error) {
+	return r.query("SELECT "+trashColumns+" FROM trash WHERE deleted_at < ? ORDER BY id", before)
+}
+
+// Delete 删除条目记录
+func (r *TrashRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM trash WHERE id = ?", id)
+	return err
+}
+
+func (r *TrashRepository) query(q string, args ...interface{}) ([]TrashItem, error) {
+	rows, err := r.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []TrashItem{}
+	for rows.Next() {
+		t, err := scanTrash(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, rows.Err()
+}
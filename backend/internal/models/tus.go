@@ -0,0 +1,96 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TusUpload 断点续传（tus 协议）中的未完成上传
+type TusUpload struct {
+	ID          string    `json:"id"`
+	OwnerID     int64     `json:"owner_id"`
+	SpaceID     int64     `json:"space_id"`
+	FolderID    int64     `json:"folder_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	IsHidden    bool      `json:"is_hidden"`
+	Length      int64     `json:"length"`   // Upload-Length
+	Offset      int64     `json:"offset"`   // 已接收字节数
+	Metadata    string    `json:"metadata"` // 原始 Upload-Metadata 头，HEAD 时原样返回
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+const tusColumns = "id,owner_id,space_id,folder_id,filename,content_type,is_hidden,length,upload_offset,metadata,created_at,updated_at"
+
+// TusRepository 负责 tus_uploads 表的读写
+type TusRepository struct {
+	db *sql.DB
+}
+
+func NewTusRepository(conn *sql.DB) *TusRepository {
+	return &TusRepository{db: conn}
+}
+
+func scanTus(row rowScanner) (*TusUpload, error) {
+	var u TusUpload
+	err := row.Scan(&u.ID, &u.OwnerID, &u.SpaceID, &u.FolderID, &u.Filename, &u.ContentType, &u.IsHidden,
+		&u.Length, &u.Offset, &u.Metadata, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Create 插入新的上传
+func (r *TusRepository) Create(u *TusUpload) error {
+	now := time.Now()
+	u.CreatedAt, u.UpdatedAt = now, now
+	_, err := r.db.Exec(
+		"INSERT INTO tus_uploads("+tusColumns+") VALUES(?,?,?,?,?,?,?,?,?,?,?,?)",
+		u.ID, u.OwnerID, u.SpaceID, u.FolderID, u.Filename, u.ContentType, u.IsHidden,
+		u.Length, u.Offset, u.Metadata, u.CreatedAt, u.UpdatedAt,
+	)
+	return err
+}
+
+// Get 按 ID 查询，不存在时返回 nil, nil
+func (r *TusRepository) Get(id string) (*TusUpload, error) {
+	u, err := scanTus(r.db.QueryRow("SELECT "+tusColumns+" FROM tus_uploads WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return u, err
+}
+
+// UpdateOffset 记录新的偏移量并刷新活跃时间
+func (r *TusRepository) UpdateOffset(u *TusUpload) error {
+	u.UpdatedAt = time.Now()
+	_, err := r.db.Exec("UPDATE tus_uploads SET upload_offset = ?, updated_at = ? WHERE id = ?", u.Offset, u.UpdatedAt, u.ID)
+	return err
+}
+
+// Delete 删除上传记录
+func (r *TusRepository) Delete(id string) error {
+	_, err := r.db.Exec("DELETE FROM tus_uploads WHERE id = ?", id)
+	return err
+}
+
+// ListStale 列出 before 之后再无活动的上传
+func (r *TusRepository) ListStale(before time.Time) ([]TusUpload, error) {
+	rows, err := r.db.Query("SELECT "+tusColumns+" FROM tus_uploads WHERE updated_at < ?", before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []TusUpload{}
+	for rows.Next() {
+		u, err := scanTus(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *u)
+	}
+	return list, rows.Err()
+}
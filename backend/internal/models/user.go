@@ -3,77 +3,150 @@ package models
 import (
 	"database/sql"
 	"time"
-
-	"familydrive/internal/db"
 )
 
+// User 家庭成员账号。SQLite 与 MySQL 中的 users 表结构相同
 type User struct {
 	ID           int64     `json:"id"`
-	Name         string    `json:"name"`
+	Username     string    `json:"username"`
 	Email        string    `json:"email"`
 	PasswordHash string    `json:"-"`
+	Role         string    `json:"role"`
+	HouseholdID  int64     `json:"household_id"`
 	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
-func CreateUser(name, email, passwordHash string) (*User, error) {
-	res, err := db.DB().Exec("INSERT INTO users(name,email,password_hash) VALUES(?,?,?)", name, email, passwordHash)
-	if err != nil {
+const userColumns = "id,username,email,password_hash,role,household_id,created_at,updated_at"
+
+// UserRepository 负责 users 表的读写，只使用两种数据库通用的 SQL
+type UserRepository struct {
+	db *sql.DB
+}
+
+func NewUserRepository(conn *sql.DB) *UserRepository {
+	return &UserRepository{db: conn}
+}
+
+func scanUser(row rowScanner) (*User, error) {
+	var (
+		u                    User
+		createdAt, updatedAt sql.NullTime
+	)
+	if err := row.Scan(&u.ID, &u.Username, &u.Email, &u.PasswordHash, &u.Role, &u.HouseholdID, &createdAt, &updatedAt); err != nil {
 		return nil, err
 	}
-	id, _ := res.LastInsertId()
-	return GetUserByID(id)
+	// 旧表中的时间可能为空
+	u.CreatedAt = createdAt.Time
+	u.UpdatedAt = updatedAt.Time
+	if !updatedAt.Valid {
+		u.UpdatedAt = u.CreatedAt
+	}
+	return &u, nil
 }
 
-func GetUserByEmail(email string) (*User, error) {
-	row := db.DB().QueryRow("SELECT id,name,email,password_hash,created_at FROM users WHERE email = ?", email)
-	var u User
-	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.PasswordHash, &u.CreatedAt)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+// Create 插入新用户，并回填 ID 和时间
+func (r *UserRepository) Create(u *User) error {
+	return insertUser(r.db, u)
+}
+
+// execer 为 *sql.DB 和 *sql.Tx 共有的写入方法
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertUser(db execer, u *User) error {
+	u.CreatedAt = time.Now().UTC()
+	u.UpdatedAt = u.CreatedAt
+	res, err := db.Exec("INSERT INTO users(username,email,password_hash,role,household_id,created_at,updated_at) VALUES(?,?,?,?,?,?,?)",
+		u.Username, u.Email, u.PasswordHash, u.Role, u.HouseholdID, u.CreatedAt, u.UpdatedAt)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return &u, nil
+	u.ID, err = res.LastInsertId()
+	return err
 }
 
-func GetUserByID(id int64) (*User, error) {
-	row := db.DB().QueryRow("SELECT id,name,email,password_hash,created_at FROM users WHERE id = ?", id)
-	var u User
-	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.PasswordHash, &u.CreatedAt)
+func (r *UserRepository) getBy(column string, value interface{}) (*User, error) {
+	u, err := scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE "+column+" = ?", value))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
+	return u, err
+}
+
+// GetByID 按 ID 查询，不存在时返回 nil, nil
+func (r *UserRepository) GetByID(id int64) (*User, error) {
+	return r.getBy("id", id)
+}
+
+// GetByEmail 按邮箱查询，不存在时返回 nil, nil
+func (r *UserRepository) GetByEmail(email string) (*User, error) {
+	return r.getBy("email", email)
+}
+
+// GetByUsername 按用户名查询，不存在时返回 nil, nil
+func (r *UserRepository) GetByUsername(username string) (*User, error) {
+	return r.getBy("username", username)
+}
+
+// List 列出全部用户，按 ID 排序
+func (r *UserRepository) List() ([]User, error) {
+	return r.list("SELECT " + userColumns + " FROM users ORDER BY id")
+}
+
+// ListByHousehold 列出家庭成员，按 ID 排序
+func (r *UserRepository) ListByHousehold(householdID int64) ([]User, error) {
+	return r.list("SELECT "+userColumns+" FROM users WHERE household_id = ? ORDER BY id", householdID)
+}
+
+func (r *UserRepository) list(query string, args ...interface{}) ([]User, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
-	return &u, nil
+	defer rows.Close()
+
+	list := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *u)
+	}
+	return list, rows.Err()
 }
 
-func StoreRefreshToken(token string, userID int64, expiresAt time.Time) error {
-	_, err := db.DB().Exec("INSERT INTO refresh_tokens(token,user_id,expires_at) VALUES(?,?,?)", token, userID, expiresAt)
-	return err
+// Count 统计用户总数
+func (r *UserRepository) Count() (int, error) {
+	var n int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n)
+	return n, err
 }
 
-func GetRefreshTokenOwner(token string) (int64, time.Time, error) {
-	row := db.DB().QueryRow("SELECT user_id,expires_at FROM refresh_tokens WHERE token = ?", token)
-	var uid int64
-	var expStr string
-	err := row.Scan(&uid, &expStr)
-	if err == sql.ErrNoRows {
-		return 0, time.Time{}, sql.ErrNoRows
-	}
-	if err != nil {
-		return 0, time.Time{}, err
-	}
-	exp, err := time.Parse(time.RFC3339, expStr)
+// CountByRole 统计某个角色的用户数
+func (r *UserRepository) CountByRole(role string) (int, error) {
+	var n int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM users WHERE role = ?", role).Scan(&n)
+	return n, err
+}
+
+// SetRole 修改用户角色，返回 false 表示用户不存在
+func (r *UserRepository) SetRole(id int64, role string) (bool, error) {
+	res, err := r.db.Exec("UPDATE users SET role = ?, updated_at = ? WHERE id = ?", role, time.Now().UTC(), id)
 	if err != nil {
-		return 0, time.Time{}, err
+		return false, err
 	}
-	return uid, exp, nil
+	n, err := res.RowsAffected()
+	return n == 1, err
 }
 
-func RevokeRefreshToken(token string) error {
-	_, err := db.DB().Exec("DELETE FROM refresh_tokens WHERE token = ?", token)
-	return err
+// AssignOrphans 把还不属于任何家庭的用户归入 householdID，返回归入的人数
+func (r *UserRepository) AssignOrphans(householdID int64) (int64, error) {
+	res, err := r.db.Exec("UPDATE users SET household_id = ? WHERE household_id = 0", householdID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
 }
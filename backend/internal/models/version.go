@@ -0,0 +1,158 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// FileVersion 文件的一个历史版本。每次上传都会生成一条，
+// Path 为空表示当前版本（内容见 files.blob），否则为归档内容所在的存储路径
+type FileVersion struct {
+	ID          int64     `json:"id"`
+	FileID      int64     `json:"file_id"`
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum"`
+	ContentType string    `json:"content_type"`
+	UploaderID  int64     `json:"uploader_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// IsCurrent 是否为当前版本
+func (v *FileVersion) IsCurrent() bool {
+	return v.Path == ""
+}
+
+const versionColumns = "id,file_id,path,size,checksum,content_type,uploader_id,created_at"
+
+// VersionRepository 负责 file_versions 表的读写
+type VersionRepository struct {
+	db *sql.DB
+}
+
+func NewVersionRepository(conn *sql.DB) *VersionRepository {
+	return &VersionRepository{db: conn}
+}
+
+func scanVersion(row rowScanner) (*FileVersion, error) {
+	var v FileVersion
+	err := row.Scan(&v.ID, &v.FileID, &v.Path, &v.Size, &v.Checksum, &v.ContentType, &v.UploaderID, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// Create 插入新版本，并回填 ID
+func (r *VersionRepository) Create(v *FileVersion) error {
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	res, err := r.db.Exec(
+		"INSERT INTO file_versions(file_id,path,size,checksum,content_type,uploader_id,created_at) VALUES(?,?,?,?,?,?,?)",
+		v.FileID, v.Path, v.Size, v.Checksum, v.ContentType, v.UploaderID, v.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	v.ID, err = res.LastInsertId()
+	return err
+}
+
+// GetByID 按 ID 查询，不存在时返回 nil, nil
+func (r *VersionRepository) GetByID(id int64) (*FileVersion, error) {
+	v, err := scanVersion(r.db.QueryRow("SELECT "+versionColumns+" FROM file_versions WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return v, err
+}
+
+// Current 查询文件的当前版本，不存在时返回 nil, nil
+func (r *VersionRepository) Current(fileID int64) (*FileVersion, error) {
+	v, err := scanVersion(r.db.QueryRow("SELECT "+versionColumns+" FROM file_versions WHERE file_id = ? AND path = '' ORDER BY id DESC LIMIT 1", fileID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return v, err
+}
+
+// Archive 把版本标记为历史版本，记录归档路径和归档时的内容信息
+func (r *VersionRepository) Archive(v *FileVersion) error {
+	_, err := r.db.Exec(
+		"UPDATE file_versions SET path=?,size=?,checksum=?,content_type=? WHERE id=?",
+		v.Path, v.Size, v.Checksum, v.ContentType, v.ID,
+	)
+	return err
+}
+
+// ListByFile 列出文件的全部版本，最新的在前
+func (r *VersionRepository) ListByFile(fileID int64) ([]FileVersion, error) {
+	return r.query("SELECT "+versionColumns+" FROM file_versions WHERE file_id = ? ORDER BY id DESC", fileID)
+}
+
+// ListArchived 列出全部历史版本
+func (r *VersionRepository) ListArchived() ([]FileVersion, error) {
+	return r.query("SELECT " + versionColumns + " FROM file_versions WHERE path <> '' ORDER BY id")
+}
+
+// FilesWithArchivedBefore 列出有早于 before 的历史版本的文件 ID
+func (r *VersionRepository) FilesWithArchivedBefore(before time.Time) ([]int64, error) {
+	rows, err := r.db.Query("SELECT DISTINCT file_id FROM file_versions WHERE path <> '' AND created_at < ?", before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Backfill 为还没有版本记录的文件（引入版本历史之前上传、或启动时收养的）补建当前版本
+func (r *VersionRepository) Backfill() (int64, error) {
+	res, err := r.db.Exec(`
+INSERT INTO file_versions(file_id,path,size,checksum,content_type,uploader_id,created_at)
+    SELECT id, '', size, checksum, content_type, owner_id, updated_at FROM files
+    WHERE NOT EXISTS (SELECT 1 FROM file_versions v WHERE v.file_id = files.id AND v.path = '')`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Delete 删除版本记录
+func (r *VersionRepository) Delete(id int64) error {
+	_, err := r.db.Exec("DELETE FROM file_versions WHERE id = ?", id)
+	return err
+}
+
+// DeleteByFile 删除文件的全部版本记录
+func (r *VersionRepository) DeleteByFile(fileID int64) error {
+	_, err := r.db.Exec("DELETE FROM file_versions WHERE file_id = ?", fileID)
+	return err
+}
+
+func (r *VersionRepository) query(q string, args ...interface{}) ([]FileVersion, error) {
+	rows, err := r.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []FileVersion{}
+	for rows.Next() {
+		v, err := scanVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *v)
+	}
+	return list, rows.Err()
+}
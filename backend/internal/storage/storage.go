@@ -0,0 +1,155 @@
+// Package storage 文件内容的存储后端：本地磁盘或 S3 兼容的对象存储（如 MinIO、NAS 自带的对象存储）
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// ErrNotExist 对象不存在，可用 errors.Is(err, fs.ErrNotExist) 判断
+var ErrNotExist = fs.ErrNotExist
+
+// ObjectInfo 对象的基本信息
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Storage 按 key（以 / 分隔的相对路径）存取内容
+type Storage interface {
+	// Put 写入 key，size 为 r 的长度，未知时传 -1；写入是原子的，中途失败不会留下半截内容
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get 读取 key 从 offset 开始的 length 个字节，length < 0 表示读到末尾
+	Get(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Stat 查询对象信息，不存在时返回 ErrNotExist
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	// Delete 删除对象，不存在时不报错
+	Delete(ctx context.Context, key string) error
+	// List 列出 key 以 prefix 开头的全部对象
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Move 把 src 改名为 dst，dst 已存在时被覆盖；src 不存在时返回 ErrNotExist
+	Move(ctx context.Context, src, dst string) error
+}
+
+// Config 存储配置，Driver 为 local（默认）或 s3
+type Config struct {
+	Driver string
+	Root   string // local：根目录
+
+	// s3：Endpoint 形如 http://nas.local:9000，按路径风格访问 Bucket，对象名前加 Prefix
+	Endpoint  string
+	Region    string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+}
+
+// Open 按配置创建存储
+func Open(cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocal(cfg.Root)
+	case "s3":
+		return NewS3(cfg)
+	default:
+		return nil, fmt.Errorf("未知的存储驱动: %s", cfg.Driver)
+	}
+}
+
+// PutFile 把本地文件 path 写入 key 并删除原文件，本地存储直接改名
+func PutFile(ctx context.Context, s Storage, key, path string) error {
+	if l, ok := s.(*Local); ok {
+		return l.rename(path, key)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil {
+		err = s.Put(ctx, key, f, info.Size())
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// Reader 按需读取对象的 io.ReadSeeker（也实现了 io.ReaderAt），配合 http.ServeContent 即可支持 Range 请求
+type Reader struct {
+	ctx    context.Context
+	s      Storage
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+// NewReader 创建 key 的 Reader，size 为对象大小
+func NewReader(ctx context.Context, s Storage, key string, size int64) *Reader {
+	return &Reader{ctx: ctx, s: s, key: key, size: size}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.s.Get(r.ctx, r.key, r.offset, r.size-r.offset)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	return n, err
+}
+
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("storage: 无效的偏移 %d", offset)
+	}
+	if offset != r.offset {
+		r.Close()
+		r.offset = offset
+	}
+	return offset, nil
+}
+
+// ReadAt 读取 off 处的内容，与上次读到的位置相接时沿用已打开的连接，
+// 顺序读取压缩包等场景不必每次重新请求。会改变读取位置，不能并发调用
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if _, err := r.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Close 关闭当前的读取连接
+func (r *Reader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
@@ -29,4 +29,6 @@ type ShareLinkResponse struct {
 	AccessCount int       `json:"access_count"`
 	MaxAccess   int       `json:"max_access"`
 	IsProtected bool      `json:"is_protected"` // 是否有密码保护
+	IsFolder    bool      `json:"is_folder"`    // 是否为目录分享（下载时打包为 ZIP）
+	IsAlbum     bool      `json:"is_album"`     // 是否为相册分享（访问时显示相册页面）
 }
\ No newline at end of file
@@ -20,11 +20,18 @@ type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
 	send chan []byte
+	room string // 所在聊天室，只收到本聊天室的消息
+}
+
+// 发往某个聊天室的消息，room 为空时发给所有客户端
+type roomMessage struct {
+	room string
+	data []byte
 }
 
 type Hub struct {
 	clients    map[*Client]bool
-	broadcast  chan []byte
+	broadcast  chan roomMessage
 	register   chan *Client
 	unregister chan *Client
 	mutex      sync.RWMutex
@@ -32,7 +39,7 @@ type Hub struct {
 
 func NewHub() *Hub {
 	return &Hub{
-		broadcast:  make(chan []byte),
+		broadcast:  make(chan roomMessage),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
 		clients:    make(map[*Client]bool),
@@ -40,7 +47,12 @@ func NewHub() *Hub {
 }
 
 func (h *Hub) Broadcast(message []byte) {
-	h.broadcast <- message
+	h.broadcast <- roomMessage{data: message}
+}
+
+// BroadcastTo 只发给 room 聊天室中的客户端
+func (h *Hub) BroadcastTo(room string, message []byte) {
+	h.broadcast <- roomMessage{room: room, data: message}
 }
 
 func (h *Hub) Run() {
@@ -71,8 +83,11 @@ func (h *Hub) Run() {
 			fmt.Printf("🎯 [%s] 实际广播给 %d 个客户端\n", time.Now().Format("15:04:05"), clientCount)
 			h.mutex.RLock()
 			for client := range h.clients {
+				if message.room != "" && client.room != message.room {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- message.data:
 				default:
 					go func(c *Client) {
 						h.unregister <- c // ✅ 使用通道安全删除
@@ -95,7 +110,7 @@ func (c *Client) readPump() {
 		if err != nil {
 			break
 		}
-		c.hub.broadcast <- message
+		c.hub.broadcast <- roomMessage{room: c.room, data: message}
 	}
 }
 
@@ -116,7 +131,8 @@ func (c *Client) writePump() {
 	}
 }
 
-func ServeWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request) {
+// ServeWebSocket 把连接加入 room 聊天室
+func ServeWebSocket(hub *Hub, room string, w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Println("WebSocket升级失败:", err)
@@ -127,6 +143,7 @@ func ServeWebSocket(hub *Hub, w http.ResponseWriter, r *http.Request) {
 		hub:  hub,
 		conn: conn,
 		send: make(chan []byte, 256),
+		room: room,
 	}
 
 	client.hub.register <- client